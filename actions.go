@@ -3,9 +3,12 @@ package ishell
 import (
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/liqianrain/readline"
 )
 
 // Actions are actions that can be performed by a shell.
@@ -164,19 +167,19 @@ func (s *shellActionsImpl) ShowPrompt(show bool) {
 }
 
 func (s *shellActionsImpl) Cmds() []*Cmd {
+	defer s.rootCmd.rlockTree()()
+
 	var cmds []*Cmd
 	for _, cmd := range s.rootCmd.staticChildren {
 		cmds = append(cmds, cmd)
 	}
 
-	if s.rootCmd.paramChild != nil {
-		cmds = append(cmds, s.rootCmd.paramChild)
-	}
+	cmds = append(cmds, s.rootCmd.paramChildren...)
 	return cmds
 }
 
 func (s *shellActionsImpl) ClearScreen() error {
-	return clearScreen(s.Shell)
+	return s.Shell.ClearScreen()
 }
 
 func (s *shellActionsImpl) ShowPaged(text string) error {
@@ -192,21 +195,40 @@ func (s *shellActionsImpl) Stop() {
 }
 
 func (s *shellActionsImpl) HelpText() string {
-	return s.rootCmd.HelpText()
+	return s.rootCmd.helpTextWith(s.helpConfig())
+}
+
+// PrintPaged prints text, through the pager if the shell is interactive
+// and text does not fit in the terminal.
+func (s *Shell) PrintPaged(text string) {
+	if s.Active() && s.isTerminal() {
+		f, _ := s.outFile()
+		_, height, err := readline.GetSize(int(f.Fd()))
+		if err == nil && strings.Count(text, "\n") >= height {
+			if err := showPagedReader(s, strings.NewReader(text)); err == nil {
+				return
+			}
+		}
+	}
+	s.Print(text)
 }
 
 func showPagedReader(s *Shell, r io.Reader) error {
 	var cmd *exec.Cmd
 
-	if s.pager == "" {
+	pager, args := s.pager, s.pagerArgs
+	if env := strings.Fields(os.Getenv("PAGER")); pager == "" && len(env) > 0 {
+		pager, args = env[0], env[1:]
+	}
+	if pager == "" {
 		if runtime.GOOS == "windows" {
-			s.pager = "more"
+			pager = "more"
 		} else {
-			s.pager = "less"
+			pager = "less"
 		}
 	}
 
-	cmd = exec.Command(s.pager, s.pagerArgs...)
+	cmd = exec.Command(pager, args...)
 	cmd.Stdout = s.writer
 	cmd.Stderr = s.writer
 	cmd.Stdin = r
@@ -0,0 +1,57 @@
+package ishell
+
+import "fmt"
+
+// maxAliasDepth is the number of nested alias expansions allowed for
+// one input line.
+const maxAliasDepth = 16
+
+// AddAlias adds an alias that replaces name with expansion when name is
+// the first word of the input. The expansion is tokenized like input
+// and the remaining words are appended to it. An empty expansion
+// removes the alias.
+func (s *Shell) AddAlias(name, expansion string) {
+	if name == "" {
+		panic("alias name must not be empty")
+	}
+	if expansion == "" {
+		delete(s.aliases, name)
+		return
+	}
+	if s.aliases == nil {
+		s.aliases = make(map[string]string)
+	}
+	s.aliases[name] = expansion
+}
+
+// Aliases returns a copy of the aliases added with AddAlias.
+func (s *Shell) Aliases() map[string]string {
+	aliases := make(map[string]string, len(s.aliases))
+	for name, expansion := range s.aliases {
+		aliases[name] = expansion
+	}
+	return aliases
+}
+
+// expandAliases substitutes the aliases of the first word of line.
+// An alias is not expanded again in its own expansion, so "ls" can be an
+// alias of "ls -l".
+func (s *Shell) expandAliases(line []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for depth := 0; len(line) > 0; depth++ {
+		expansion, ok := s.aliases[line[0]]
+		if !ok || seen[line[0]] {
+			break
+		}
+		if depth == maxAliasDepth {
+			return nil, fmt.Errorf("alias %q is nested too deeply", line[0])
+		}
+		seen[line[0]] = true
+		args, err := splitArgs(expansion)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %w", line[0], err)
+		}
+		line = append(args, line[1:]...)
+	}
+	return line, nil
+}
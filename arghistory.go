@@ -0,0 +1,63 @@
+package ishell
+
+import "sync"
+
+// maxArgHistory is the number of values remembered per history key.
+const maxArgHistory = 50
+
+// argHistory is the values previously given to the arguments with a
+// HistoryKey, most recent first.
+type argHistory struct {
+	values map[string][]string
+	sync.Mutex
+}
+
+func (h *argHistory) add(key, value string) {
+	h.Lock()
+	defer h.Unlock()
+	if h.values == nil {
+		h.values = make(map[string][]string)
+	}
+	values := []string{value}
+	for _, v := range h.values[key] {
+		if v != value && len(values) < maxArgHistory {
+			values = append(values, v)
+		}
+	}
+	h.values[key] = values
+}
+
+func (h *argHistory) get(key string) []string {
+	h.Lock()
+	defer h.Unlock()
+	return append([]string(nil), h.values[key]...)
+}
+
+// recordArgs remembers the values given to the arguments of cmd with a
+// HistoryKey. Default values are not recorded.
+func (s *Shell) recordArgs(cmd *Cmd, r resolvedArgs) {
+	for _, arg := range cmd.Args {
+		if arg.HistoryKey == "" {
+			continue
+		}
+		values, ok := r.lists[arg.Name]
+		if !ok {
+			if value, ok := r.values[arg.Name]; ok {
+				values = []string{value}
+			}
+		}
+		for _, value := range values {
+			if value != arg.Default {
+				s.argHistory.add(arg.HistoryKey, value)
+			}
+		}
+	}
+}
+
+// ClearArgHistory forgets the values recorded for the arguments with
+// HistoryKey key.
+func (s *Shell) ClearArgHistory(key string) {
+	s.argHistory.Lock()
+	defer s.argHistory.Unlock()
+	delete(s.argHistory.values, key)
+}
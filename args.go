@@ -0,0 +1,273 @@
+package ishell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pairArg returns the pair argument of c named name or having the alias
+// name, or nil if there is none.
+func (c *Cmd) pairArg(name string) *Arg {
+	for i := range c.Args {
+		if c.Args[i].Pair && (c.Args[i].Name == name || containsString(c.Args[i].Aliases, name)) {
+			return &c.Args[i]
+		}
+	}
+	return nil
+}
+
+// inlinePair splits token of the form key=value where key is the name
+// of a pair argument of c. It returns nil if token is not such a pair.
+func (c *Cmd) inlinePair(token string) (*Arg, string) {
+	i := strings.IndexByte(token, '=')
+	if i < 1 {
+		return nil, ""
+	}
+	return c.pairArg(token[:i]), token[i+1:]
+}
+
+// positionalArgs returns the non pair arguments of c in declaration order.
+func (c *Cmd) positionalArgs() []*Arg {
+	var args []*Arg
+	for i := range c.Args {
+		if !c.Args[i].Pair {
+			args = append(args, &c.Args[i])
+		}
+	}
+	return args
+}
+
+// defaultArgSeparator is the token after which all the arguments are
+// positional.
+const defaultArgSeparator = "--"
+
+// nextPositional returns the positional argument the token following
+// args is assigned to, or nil if all of them are filled. Tokens after
+// sep are positional.
+func (c *Cmd) nextPositional(args []string, sep string) *Arg {
+	positional := c.positionalArgs()
+	pos := 0
+	literal := false
+	for i := 0; i < len(args); i++ {
+		if !literal && sep != "" && args[i] == sep {
+			literal = true
+			continue
+		}
+		if !literal && c.pairArg(args[i]) != nil {
+			i++
+			continue
+		}
+		if arg, _ := c.inlinePair(args[i]); !literal && arg != nil {
+			continue
+		}
+		if pos < len(positional) && !positional[pos].Variadic {
+			pos++
+		}
+	}
+	if pos < len(positional) {
+		return positional[pos]
+	}
+	return nil
+}
+
+// resolvedArgs is command arguments associated with their declared Args.
+type resolvedArgs struct {
+	values map[string]string
+	lists  map[string][]string
+}
+
+// has reports if a value was resolved for the argument named name.
+func (r resolvedArgs) has(name string) bool {
+	if _, ok := r.values[name]; ok {
+		return true
+	}
+	_, ok := r.lists[name]
+	return ok
+}
+
+// checkArgs panics if the declared Args of c are malformed.
+func (c *Cmd) checkArgs() {
+	var optional *Arg
+	names := make(map[string]bool)
+	for i, arg := range c.Args {
+		if len(arg.Aliases) > 0 && !arg.Pair {
+			panic("argument '" + arg.Name + "' of '" + c.Name + "' has aliases but is not a pair")
+		}
+		for _, name := range append([]string{arg.Name}, arg.Aliases...) {
+			if names[name] {
+				panic("argument name '" + name + "' of '" + c.Name + "' is used twice")
+			}
+			names[name] = true
+		}
+		if arg.Variadic && i != len(c.Args)-1 {
+			panic("variadic argument '" + arg.Name + "' of '" + c.Name + "' must be the last argument")
+		}
+		if arg.Pair {
+			continue
+		}
+		if arg.Optional && optional == nil {
+			optional = &c.Args[i]
+		}
+		if !arg.Optional && optional != nil {
+			panic("required argument '" + arg.Name + "' of '" + c.Name + "' follows optional argument '" + optional.Name + "'")
+		}
+	}
+	if c.MinArgs < 0 || c.MaxArgs > 0 && c.MaxArgs < c.MinArgs {
+		panic("invalid argument count bounds of '" + c.Name + "'")
+	}
+}
+
+// ValidateArgCount returns an error if n arguments are out of the
+// MinArgs and MaxArgs bounds of c.
+func (c *Cmd) ValidateArgCount(n int) error {
+	if c.MinArgs == 0 && c.MaxArgs == 0 {
+		return nil
+	}
+	switch {
+	case c.MaxArgs <= 0 && n < c.MinArgs:
+		return fmt.Errorf("%q accepts at least %d arguments, got %d", c.Name, c.MinArgs, n)
+	case c.MaxArgs > 0 && (n < c.MinArgs || n > c.MaxArgs):
+		if c.MinArgs == c.MaxArgs {
+			return fmt.Errorf("%q accepts %d arguments, got %d", c.Name, c.MinArgs, n)
+		}
+		return fmt.Errorf("%q accepts between %d and %d arguments, got %d", c.Name, c.MinArgs, c.MaxArgs, n)
+	}
+	return nil
+}
+
+// parseArgs associates args with the declared Args of c.
+// Pair arguments consume the token following their name, or the value
+// of a name=value token. Other tokens are assigned to positional
+// arguments in order. A variadic argument collects all the remaining
+// positional tokens. The tokens after the first sep are positional
+// even if they look like pair arguments.
+func (c *Cmd) parseArgs(args []string, sep string) (resolvedArgs, error) {
+	r := resolvedArgs{
+		values: make(map[string]string),
+		lists:  make(map[string][]string),
+	}
+	positional := c.positionalArgs()
+	pos := 0
+	literal := false
+	for i := 0; i < len(args); i++ {
+		if !literal && sep != "" && args[i] == sep {
+			literal = true
+			continue
+		}
+		if arg := c.pairArg(args[i]); !literal && arg != nil {
+			if i+1 >= len(args) {
+				return r, fmt.Errorf("missing value for argument: %s", arg.Name)
+			}
+			i++
+			r.values[arg.Name] = args[i]
+			if arg.Repeatable {
+				r.lists[arg.Name] = append(r.lists[arg.Name], args[i])
+			}
+			continue
+		}
+		if arg, value := c.inlinePair(args[i]); !literal && arg != nil {
+			r.values[arg.Name] = value
+			if arg.Repeatable {
+				r.lists[arg.Name] = append(r.lists[arg.Name], value)
+			}
+			continue
+		}
+		if pos >= len(positional) {
+			continue
+		}
+		if arg := positional[pos]; arg.Variadic {
+			r.lists[arg.Name] = append(r.lists[arg.Name], args[i])
+			continue
+		}
+		r.values[positional[pos].Name] = args[i]
+		pos++
+	}
+	return r, nil
+}
+
+// resolveArgs checks the count of args, parses them, fills in defaults
+// of omitted optional arguments, checks that every required argument is
+// present and runs the argument validators. sep is not counted.
+func (c *Cmd) resolveArgs(args []string, sep string) (resolvedArgs, error) {
+	n := len(args)
+	if sep != "" && containsString(args, sep) {
+		n--
+	}
+	if err := c.ValidateArgCount(n); err != nil {
+		return resolvedArgs{}, err
+	}
+	r, err := c.parseArgs(args, sep)
+	if err != nil {
+		return r, err
+	}
+	for _, arg := range c.Args {
+		if r.has(arg.Name) {
+			continue
+		}
+		if !arg.Optional {
+			return r, fmt.Errorf("missing required argument: %s", arg.Name)
+		}
+		if arg.Default == "" {
+			continue
+		}
+		if arg.Variadic {
+			r.lists[arg.Name] = []string{arg.Default}
+		} else {
+			r.values[arg.Name] = arg.Default
+		}
+	}
+	for _, arg := range c.Args {
+		if err := r.validate(arg); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// validate checks the resolved values of arg against its choices and
+// its validator.
+func (r resolvedArgs) validate(arg Arg) error {
+	if arg.Validate == nil && len(arg.Choices) == 0 {
+		return nil
+	}
+	values, ok := r.lists[arg.Name]
+	if v, found := r.values[arg.Name]; found && !ok {
+		values = []string{v}
+	}
+	for _, v := range values {
+		if len(arg.Choices) > 0 && !containsString(arg.Choices, v) {
+			return fmt.Errorf("invalid choice for %s: must be one of %s", arg.Name, strings.Join(arg.Choices, ", "))
+		}
+		if arg.Validate == nil {
+			continue
+		}
+		if err := arg.Validate(v); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", arg.Name, err)
+		}
+	}
+	return nil
+}
+
+// hasSuggestions reports if completion offers values for a.
+func (a *Arg) hasSuggestions() bool {
+	return a.Suggest != nil || len(a.Choices) > 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateArgs checks args against the declared Args of c, with "--"
+// as the argument separator.
+// It returns an error if the count of args is out of bounds, if a
+// required argument is missing or if an argument validator rejects its
+// value.
+func (c *Cmd) ValidateArgs(args []string) error {
+	_, err := c.resolveArgs(args, defaultArgSeparator)
+	return err
+}
@@ -0,0 +1,62 @@
+package ishell
+
+import (
+	"io"
+	"os"
+
+	"github.com/liqianrain/readline"
+)
+
+// isBatch reports if the shell reads the standard input and it is not
+// a terminal, e.g. piped commands.
+func (s *Shell) isBatch() bool {
+	return s.osStdin && !readline.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// runBatch runs the lines of input as a script, without prompt nor
+// completion, until EOF or the shell stops. Errors are printed to the
+// standard error and set the exit code, the next lines still run.
+func (s *Shell) runBatch() {
+	s.batch = true
+	halt := s.haltOnError
+	s.haltOnError = false
+	defer func() { s.batch, s.haltOnError = false, halt }()
+	s.reader.scanner.SetPrompt("")
+	if err := s.RunScript(&inputReader{shell: s}); err != nil {
+		s.printError(err)
+	}
+	s.stop()
+}
+
+// ExitCode returns the exit status for the program after Run: 1 if a
+// command failed while the commands were read from a non terminal
+// standard input, 0 otherwise. e.g.
+//
+//	shell.Run()
+//	os.Exit(shell.ExitCode())
+func (s *Shell) ExitCode() int {
+	return s.exitCode
+}
+
+// inputReader reads the lines of the shell input, as an io.Reader
+// ending once the shell stops.
+type inputReader struct {
+	shell   *Shell
+	pending []byte
+}
+
+func (r *inputReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if !r.shell.Active() {
+			return 0, io.EOF
+		}
+		line, err := r.shell.reader.scanner.Readline()
+		if err != nil {
+			return 0, io.EOF
+		}
+		r.pending = []byte(line + "\n")
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -1,26 +1,71 @@
 package ishell
 
 import (
-	"bytes"
-	"fmt"
+	"regexp"
 	"sort"
 	"strings"
-	"text/tabwriter"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Cmd is a shell command handler.
 type (
 	Cmd struct {
-		// Command name.
+		// Command name. A path like "users/:id" or "show routes"
+		// adds the command under intermediate commands created as
+		// needed.
 		Name string
-		// Command name aliases.
+		// Command name aliases. They are indexed by AddCmd and must
+		// not be changed once the command is added.
 		Aliases []string
 		// Function to execute for the command.
 		Func func(c *Context)
+		// RunE is executed for the command in place of Func. The
+		// returned error is reported like the one set with Context.Err.
+		RunE func(c *Context) error
+		// Before is called before Func of the command and its subcommands.
+		Before func(c *Context)
+		// After is called after Func of the command and its subcommands,
+		// even if Func panics.
+		After func(c *Context)
 		// One liner help message for the command.
 		Help string
 		// More descriptive help message for the command.
 		LongHelp string
+		// LongHelpFunc generates the descriptive help message of the
+		// command. It takes precedence over LongHelp.
+		LongHelpFunc func(c *Cmd) string
+		// Examples are command lines listed verbatim in the help.
+		Examples []string
+		// Deprecated marks the command as deprecated. The message is
+		// printed before the command runs e.g. "use 'xyz' instead".
+		// Deprecated commands are not listed in help unless verbose.
+		Deprecated string
+		// Hidden excludes the command from help and completion.
+		// It can still be executed. Subcommands are not affected.
+		Hidden bool
+		// Timeout is the deadline of the Ctx of the command context.
+		// Zero means no timeout.
+		Timeout time.Duration
+		// Category groups the command with others under a heading
+		// in the help of its parent.
+		Category string
+		// Authorize is called before Func. A non-nil error aborts the
+		// command. Only the Authorize of the executed command is called,
+		// not the ones of its parents.
+		Authorize func(c *Context) error
+		// Enabled reports if the command can run, e.g. once a
+		// connection is established. A disabled command is refused
+		// before Authorize is called and is marked disabled in help
+		// and completion, see Shell.SetHideDisabled. nil means
+		// enabled.
+		Enabled func() bool
+		// MinArgs and MaxArgs bound the number of arguments of the
+		// command. A zero or negative MaxArgs means no upper bound.
+		// Both zero disables the check.
+		MinArgs int
+		MaxArgs int
 
 		Args []Arg
 
@@ -39,12 +84,45 @@ type (
 		// CompleterWithPrefix takes precedence
 		CompleterWithPrefix func(prefix string, args []string) []string
 
+		// NoCompletion disables the completion of the command's
+		// subcommands and arguments, e.g. for free text input.
+		NoCompletion bool
+		// CompletionDisabled disables the completion of the command's
+		// subcommands and arguments while it returns true. It composes
+		// with NoCompletion and the shell wide disabling.
+		CompletionDisabled func() bool
+
+		// Pattern is a regular expression constraining the values
+		// matched by a param command e.g. `[0-9]+` for ':id'.
+		// The whole value must match. It is ignored for static commands.
+		Pattern string
+		// ParamType is the type of the values of a param command. With
+		// SetStrictParams, values not parsing as ParamType are not
+		// matched by the param. It is ignored for static commands.
+		ParamType ParamType
+
 		// subcommands.
 		//children map[string]*Cmd
 		parent         *Cmd
 		staticChildren map[string]*Cmd
-		paramChild     *Cmd
+		paramChildren  []*Cmd
 		kind           kind
+		pattern        *regexp.Regexp
+		seq            uint64
+		sortMode       SortMode
+		helpCmdName    string
+
+		// lookup indexes of the static subcommands
+		aliasIndex    map[string]*Cmd
+		sortedNames   []string
+		sortedAliases []string
+
+		// placeholder tells the command was created for a path. It is
+		// filled by a later definition of the command.
+		placeholder bool
+
+		// tree guards the subcommands of the tree of the command.
+		tree *sync.RWMutex
 	}
 
 	Arg struct {
@@ -53,33 +131,92 @@ type (
 		Pair     bool
 		Optional bool
 		Help     string
+
+		// Default is the value used for an optional argument
+		// that is not supplied.
+		Default string
+
+		// Variadic makes a positional argument collect all the
+		// remaining args. Only the last argument can be variadic.
+		Variadic bool
+
+		// Repeatable allows a pair argument to be supplied more than
+		// once. All its values are available with Context.ArgList.
+		Repeatable bool
+
+		// Aliases are other names of a pair argument, e.g. "-v" for
+		// "--verbose". Its value is resolved under Name.
+		Aliases []string
+
+		// Validate is called with the value of the argument before
+		// the command runs. A non-nil error aborts the command.
+		Validate func(value string) error
+
+		// Suggest returns the completion candidates for the value of
+		// the argument starting with prefix.
+		Suggest func(prefix string) []string
+
+		// Choices are the valid values of the argument. They are
+		// offered by completion and other values are rejected. Empty
+		// means any value.
+		Choices []string
+
+		// HistoryKey enables completion of the values previously
+		// given to the argument. Arguments sharing a HistoryKey share
+		// their values.
+		HistoryKey string
 	}
 
 	kind uint8
+
+	// SortMode is the order in which subcommands are listed.
+	SortMode uint8
 )
 
 const (
 	StaticKind kind = iota
 	ParamKind
+	CatchAllKind
 
-	paramLabel = byte(':')
-	spliter    = "/"
+	paramLabel    = byte(':')
+	catchAllLabel = byte('*')
+	spliter       = "/"
 )
 
+const (
+	// SortAlphabetical lists subcommands by name.
+	SortAlphabetical SortMode = iota
+	// SortRegistration lists subcommands in the order they were added.
+	SortRegistration
+)
+
+// cmdSeq is the registration sequence of commands, shared by all the
+// command trees.
+var cmdSeq uint64
+
+// isParamName reports if name is the name of a param or catch-all command.
+func isParamName(name string) bool {
+	return name != "" && (name[0] == paramLabel || name[0] == catchAllLabel)
+}
+
 func addCmd(parent, child *Cmd) *Cmd {
 	name := child.Name
-	if name[0] == paramLabel {
-		_cmd := parent.paramChild
-		if _cmd == nil {
-			child.Name = name[1:]
-			child.kind = ParamKind
-
-			_cmd = child
-			child.parent = parent
-			parent.paramChild = _cmd
+	seq := atomic.AddUint64(&cmdSeq, 1)
+	invalidateHelp()
+	if isParamName(name) {
+		if _cmd := parent.paramChild(name[1:]); _cmd != nil {
+			return _cmd
 		}
-
-		return parent.paramChild
+		child.Name = name[1:]
+		child.kind = ParamKind
+		if name[0] == catchAllLabel {
+			child.kind = CatchAllKind
+		}
+		child.seq = seq
+		child.parent = parent
+		child.joinTree(parent)
+		parent.paramChildren = append(parent.paramChildren, child)
+		return child
 	}
 
 	if parent.staticChildren == nil {
@@ -88,133 +225,616 @@ func addCmd(parent, child *Cmd) *Cmd {
 
 	if _, ok := parent.staticChildren[name]; !ok {
 		child.kind = StaticKind
+		child.seq = seq
 		child.parent = parent
+		child.joinTree(parent)
 		parent.staticChildren[name] = child
+		parent.indexChild(child)
 	}
 	return parent.staticChildren[name]
 }
 
+// treesMutex guards the tree field of the commands.
+var treesMutex sync.Mutex
+
+// treeMutex returns the mutex guarding the subcommands of the tree of c,
+// shared by all its commands. It makes the tree safe to mutate while the
+// shell runs. It is only held to read or change subcommands, never while
+// a command or a user provided callback, like Enabled or a normalizer,
+// runs: these can change the tree themselves.
+func (c *Cmd) treeMutex() *sync.RWMutex {
+	treesMutex.Lock()
+	defer treesMutex.Unlock()
+	if c.tree == nil {
+		c.tree = &sync.RWMutex{}
+	}
+	return c.tree
+}
+
+// lockTree locks the tree of c for writing and returns the unlock func.
+func (c *Cmd) lockTree() func() {
+	mu := c.treeMutex()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// rlockTree locks the tree of c for reading and returns the unlock func.
+func (c *Cmd) rlockTree() func() {
+	mu := c.treeMutex()
+	mu.RLock()
+	return mu.RUnlock
+}
+
+// joinTree makes c and its subcommands share the tree mutex of parent.
+func (c *Cmd) joinTree(parent *Cmd) {
+	treesMutex.Lock()
+	defer treesMutex.Unlock()
+	if parent.tree == nil {
+		parent.tree = &sync.RWMutex{}
+	}
+	c.setTree(parent.tree)
+}
+
+func (c *Cmd) setTree(mu *sync.RWMutex) {
+	c.tree = mu
+	for _, cmd := range c.staticChildren {
+		cmd.setTree(mu)
+	}
+	for _, cmd := range c.paramChildren {
+		cmd.setTree(mu)
+	}
+}
+
 // AddCmd adds cmd as a subcommand.
+// A command created as needed for the path of another one, static or
+// param, is replaced by a later definition, which takes over its
+// subcommands. Registering a command defined already panics, whatever
+// the registration order.
+// It is safe to call while the shell is running.
 func (c *Cmd) AddCmd(cmd *Cmd) {
 	if cmd.Name == "" {
 		panic("cmd name should not be empty")
 	}
+	defer c.lockTree()()
+	if cmd.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + cmd.Pattern + ")$")
+		if err != nil {
+			panic("invalid pattern for '" + cmd.Name + "': " + err.Error())
+		}
+		cmd.pattern = pattern
+	}
+
+	names := splitPath(cmd.Name)
+	if len(names) == 0 {
+		panic("cmd name should not be empty")
+	}
 
-	cmd.Name = strings.TrimSuffix(strings.TrimPrefix(cmd.Name, spliter), spliter)
-	names := strings.Split(cmd.Name, spliter)
+	c.checkParams(names, cmd.Name)
 
-	last := c
+	// resolve the existing commands of the path and check cmd can be
+	// added before creating the missing ones, a panic leaves the tree
+	// unchanged
+	last, i := c, 0
+	for ; i < len(names)-1; i++ {
+		name := names[i]
+		if last.kind == CatchAllKind {
+			panic("catch-all '" + last.displayName() + "' cannot have subcommands")
+		}
 
-	for _, name := range names[:len(names)-1] {
-		if name[0] == paramLabel && len(name) < 2 {
-			panic("wildcards must be named with a non-empty name '" + cmd.Name + "'")
+		if !isParamName(name) {
+			if other := last.aliasChild(name); other != nil {
+				panic("command name '" + name + "' conflicts with an alias of '" + other.Name + "'")
+			}
 		}
 
-		last = addCmd(last, &Cmd{Name: name})
+		next := last.child(name)
+		if next == nil {
+			break
+		}
+		last = next
 	}
 
 	name := names[len(names)-1]
 	cmd.Name = name
+	cmd.checkArgs()
+	var placeholder *Cmd
+	if i == len(names)-1 {
+		if last.kind == CatchAllKind {
+			panic("catch-all '" + last.displayName() + "' cannot have subcommands")
+		}
+		if isParamName(name) {
+			if child := last.paramChild(name[1:]); child != nil {
+				if !child.placeholder || name[0] == catchAllLabel {
+					panic("command '" + name + "' is already registered")
+				}
+				cmd.Name = name[1:]
+				placeholder = child
+			}
+		} else if child, ok := last.staticChildren[name]; ok && child.placeholder {
+			placeholder = child
+		} else {
+			last.checkConflicts(cmd)
+		}
+		if placeholder != nil {
+			last.checkAdoption(placeholder, cmd)
+		}
+	}
+
+	for _, name := range names[i : len(names)-1] {
+		last = addCmd(last, &Cmd{Name: name, placeholder: true})
+	}
+	if placeholder != nil {
+		last.adoptPlaceholder(placeholder, cmd)
+		return
+	}
 	addCmd(last, cmd)
 }
 
+// checkAdoption panics if the definition cmd cannot replace the
+// placeholder subcommand child of c.
+func (c *Cmd) checkAdoption(child, cmd *Cmd) {
+	if !child.isParam() {
+		for _, alias := range cmd.Aliases {
+			if _, ok := c.staticChildren[alias]; ok {
+				panic("alias '" + alias + "' of '" + cmd.Name + "' conflicts with command '" + alias + "'")
+			}
+			if other := c.aliasChild(alias); other != nil && other != child {
+				panic("alias '" + alias + "' of '" + cmd.Name + "' conflicts with an alias of '" + other.Name + "'")
+			}
+		}
+	}
+	for name := range child.staticChildren {
+		if _, ok := cmd.staticChildren[name]; ok {
+			panic("command '" + name + "' is already registered")
+		}
+	}
+	for _, sub := range child.paramChildren {
+		if cmd.paramChild(sub.Name) != nil {
+			panic("command '" + sub.displayName() + "' is already registered")
+		}
+	}
+}
+
+// adoptPlaceholder replaces the placeholder subcommand child of c with
+// the definition cmd, which takes over its subcommands. checkAdoption
+// must pass first.
+func (c *Cmd) adoptPlaceholder(child, cmd *Cmd) {
+	for name, sub := range child.staticChildren {
+		if cmd.staticChildren == nil {
+			cmd.staticChildren = make(map[string]*Cmd)
+		}
+		sub.parent = cmd
+		cmd.staticChildren[name] = sub
+		cmd.indexChild(sub)
+	}
+	for _, sub := range child.paramChildren {
+		sub.parent = cmd
+		cmd.paramChildren = append(cmd.paramChildren, sub)
+	}
+
+	cmd.kind = child.kind
+	cmd.seq = child.seq
+	cmd.parent = c
+	cmd.joinTree(c)
+	if child.isParam() {
+		for i := range c.paramChildren {
+			if c.paramChildren[i] == child {
+				c.paramChildren[i] = cmd
+			}
+		}
+	} else {
+		c.unindexChild(child)
+		c.staticChildren[cmd.Name] = cmd
+		c.indexChild(cmd)
+	}
+	invalidateHelp()
+}
+
+// paramNamePattern matches the valid names of params, without their
+// ':' or '*' prefix.
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkParams panics if the param names of the path names added under c
+// are malformed, or if a param key is used twice along the path.
+func (c *Cmd) checkParams(names []string, path string) {
+	keys := make(map[string]bool)
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.isParam() {
+			keys[cmd.Name] = true
+		}
+	}
+	for i, name := range names {
+		if !isParamName(name) {
+			continue
+		}
+		if len(name) < 2 {
+			panic("wildcards must be named with a non-empty name '" + path + "'")
+		}
+		if !paramNamePattern.MatchString(name[1:]) {
+			panic("invalid param name '" + name + "' in '" + path + "'")
+		}
+		if name[0] == catchAllLabel && i != len(names)-1 {
+			panic("catch-all '" + name + "' must be the last name of '" + path + "'")
+		}
+		if keys[name[1:]] {
+			panic("duplicate param '" + name[1:] + "' in '" + path + "'")
+		}
+		keys[name[1:]] = true
+	}
+}
+
+// splitPath splits the command path name into command names. Names are
+// separated by '/' or spaces, "show routes" is the same as "show/routes".
+func splitPath(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == rune(spliter[0])
+	})
+}
+
+// aliasChild returns the static subcommand of c having name as alias.
+func (c *Cmd) aliasChild(name string) *Cmd {
+	return c.aliasIndex[name]
+}
+
+// checkConflicts panics if the name or an alias of cmd is already
+// used by a subcommand of c.
+func (c *Cmd) checkConflicts(cmd *Cmd) {
+	if _, ok := c.staticChildren[cmd.Name]; ok {
+		panic("command '" + cmd.Name + "' is already registered")
+	}
+	if other := c.aliasChild(cmd.Name); other != nil {
+		panic("command name '" + cmd.Name + "' conflicts with an alias of '" + other.Name + "'")
+	}
+	for _, alias := range cmd.Aliases {
+		if _, ok := c.staticChildren[alias]; ok {
+			panic("alias '" + alias + "' of '" + cmd.Name + "' conflicts with command '" + alias + "'")
+		}
+		if other := c.aliasChild(alias); other != nil {
+			panic("alias '" + alias + "' of '" + cmd.Name + "' conflicts with an alias of '" + other.Name + "'")
+		}
+	}
+}
+
+// HasChild reports if c has a subcommand with name or alias name.
+// Param subcommands are matched with their ':' or '*' prefixed name.
+func (c *Cmd) HasChild(name string) bool {
+	defer c.rlockTree()()
+	if isParamName(name) {
+		return c.paramChild(name[1:]) != nil
+	}
+	if _, ok := c.staticChildren[name]; ok {
+		return true
+	}
+	return c.aliasChild(name) != nil
+}
+
 // DeleteCmd deletes cmd from subcommands.
+// name can be a path like "a/b/c", in which case the intermediate
+// commands left without Func and subcommands are deleted as well.
+// It is safe to call while the shell is running.
 func (c *Cmd) DeleteCmd(name string) {
-	if name[0] == paramLabel {
-		if c.paramChild != nil && c.paramChild.Name == name[1:] {
-			c.paramChild = nil
+	defer c.lockTree()()
+
+	names := splitPath(name)
+	if len(names) == 0 {
+		return
+	}
+	path := []*Cmd{c}
+	for _, name := range names[:len(names)-1] {
+		child := path[len(path)-1].child(name)
+		if child == nil {
+			return
+		}
+		path = append(path, child)
+	}
+	path[len(path)-1].deleteChild(names[len(names)-1])
+
+	for i := len(path) - 1; i > 0; i-- {
+		cmd := path[i]
+		if cmd.runnable() || len(cmd.staticChildren) > 0 || len(cmd.paramChildren) > 0 {
+			break
+		}
+		path[i-1].deleteChild(cmd.displayName())
+	}
+}
+
+// runnable reports if c has a Func or a RunE to execute.
+func (c *Cmd) runnable() bool {
+	return c.Func != nil || c.RunE != nil
+}
+
+// disabled reports if the Enabled function of c disables it.
+func (c *Cmd) disabled() bool {
+	return c.Enabled != nil && !c.Enabled()
+}
+
+// child returns the subcommand of c registered as name, or nil.
+func (c *Cmd) child(name string) *Cmd {
+	if isParamName(name) {
+		return c.paramChild(name[1:])
+	}
+	return c.staticChildren[name]
+}
+
+func (c *Cmd) deleteChild(name string) {
+	invalidateHelp()
+	if isParamName(name) {
+		for i, cmd := range c.paramChildren {
+			if cmd.Name == name[1:] {
+				c.paramChildren = append(c.paramChildren[:i:i], c.paramChildren[i+1:]...)
+				break
+			}
 		}
 		return
 	}
 
-	delete(c.staticChildren, name)
+	if cmd, ok := c.staticChildren[name]; ok {
+		c.unindexChild(cmd)
+		delete(c.staticChildren, name)
+	}
 }
 
 // Children returns the subcommands of c.
 func (c *Cmd) Children() []*Cmd {
+	defer c.rlockTree()()
+	return c.children()
+}
+
+func (c *Cmd) children() []*Cmd {
 	var cmds []*Cmd
 	for _, cmd := range c.staticChildren {
 		cmds = append(cmds, cmd)
 	}
 
-	if c.paramChild != nil {
-		cmds = append(cmds, c.paramChild)
+	cmds = append(cmds, c.paramChildren...)
+	c.sortCmds(cmds)
+	return cmds
+}
+
+// sortCmds sorts the subcommands cmds of c in the order of Children.
+func (c *Cmd) sortCmds(cmds []*Cmd) {
+	if c.root().sortMode == SortRegistration {
+		sort.Slice(cmds, func(i, j int) bool { return cmds[i].seq < cmds[j].seq })
+	} else {
+		sort.Sort(cmdSorter(cmds))
+	}
+}
+
+// root returns the topmost parent of c.
+func (c *Cmd) root() *Cmd {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// displayName returns the name of c as registered, i.e. with
+// the ':' prefix for param commands.
+func (c *Cmd) displayName() string {
+	switch c.kind {
+	case ParamKind:
+		return string(paramLabel) + c.Name
+	case CatchAllKind:
+		return string(catchAllLabel) + c.Name
 	}
+	return c.Name
+}
+
+// isParam reports if c is a param or catch-all command.
+func (c *Cmd) isParam() bool {
+	return c.kind != StaticKind
+}
+
+// VisibleChildren returns the subcommands of c that are not hidden.
+func (c *Cmd) VisibleChildren() []*Cmd {
+	defer c.rlockTree()()
+	return c.visibleChildren()
+}
 
-	sort.Sort(cmdSorter(cmds))
+func (c *Cmd) visibleChildren() []*Cmd {
+	var cmds []*Cmd
+	for _, cmd := range c.children() {
+		if !cmd.Hidden {
+			cmds = append(cmds, cmd)
+		}
+	}
 	return cmds
 }
 
 func (c *Cmd) hasSubcommand() bool {
-	if len(c.staticChildren) > 1 || c.paramChild != nil {
+	if len(c.staticChildren) > 1 || len(c.paramChildren) > 0 {
 		return true
 	}
-	if _, ok := c.staticChildren["help"]; !ok {
+	if _, ok := c.staticChildren[c.helpName()]; !ok {
 		return len(c.staticChildren) > 0
 	}
 	return false
 }
 
-// HelpText returns the computed help of the command and its subcommands.
-func (c *Cmd) HelpText() string {
-	var b bytes.Buffer
-	p := func(s ...interface{}) {
-		fmt.Fprintln(&b)
-		if len(s) > 0 {
-			fmt.Fprintln(&b, s...)
-		}
+// helpName returns the name of the help command of the tree of c.
+func (c *Cmd) helpName() string {
+	if name := c.root().helpCmdName; name != "" {
+		return name
 	}
-	if c.LongHelp != "" {
-		p(c.LongHelp)
-	} else if c.Help != "" {
-		p(c.Help)
-	} else if c.Name != "" {
-		p(c.Name, "has no help")
+	return defaultHelpName
+}
+
+// matcher holds the options used to match input against command names.
+// The zero value matches names exactly.
+type matcher struct {
+	ignoreCase  bool
+	prefixMatch bool
+	// strictParams skips the param commands whose ParamType does not
+	// parse the input.
+	strictParams bool
+	// normalize maps input and aliases to the form they are compared
+	// in, if not nil.
+	normalize func(string) string
+}
+
+// hasPrefix reports if the command name cmdName starts with the input prefix.
+func (m matcher) hasPrefix(cmdName, prefix string) bool {
+	if m.ignoreCase {
+		return strings.HasPrefix(strings.ToLower(cmdName), strings.ToLower(prefix))
 	}
-	if c.hasSubcommand() {
-		p("Commands:")
-		w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
-		for _, child := range c.Children() {
-			fmt.Fprintf(w, "\t%s\t\t\t%s\n", child.Name, child.Help)
+	return strings.HasPrefix(cmdName, prefix)
+}
+
+// prefixChildren returns the sorted names of the static subcommands of c
+// starting with prefix.
+func prefixChildren(c *Cmd, prefix string, m matcher) []string {
+	if !m.ignoreCase {
+		return append([]string(nil), prefixRange(c.sortedNames, prefix)...)
+	}
+	var names []string
+	for k := range c.staticChildren {
+		if m.hasPrefix(k, prefix) {
+			names = append(names, k)
 		}
-		w.Flush()
-		p()
 	}
-	return b.String()
+	sort.Strings(names)
+	return names
 }
 
-// helpText returns the help of the command.
-func (c *Cmd) helpText() string {
-	if c.LongHelp != "" {
-		return c.LongHelp
+// equal reports if the input name matches the command name cmdName.
+func (m matcher) equal(name, cmdName string) bool {
+	if m.ignoreCase {
+		return strings.EqualFold(name, cmdName)
 	}
-	return c.Help
+	return name == cmdName
 }
 
 // findChildCmd returns the subcommand with matching name or alias.
-func findChildCmd(c *Cmd, name string) *Cmd {
+// The normalizer of m runs with the tree unlocked.
+func findChildCmd(c *Cmd, name string, m matcher) *Cmd {
+	unlock := c.rlockTree()
+	cmd := c.exactChild(name, m)
+	var statics []*Cmd
+	if cmd == nil && m.normalize != nil {
+		for _, k := range c.sortedNames {
+			statics = append(statics, c.staticChildren[k])
+		}
+	}
+	unlock()
+	if cmd != nil {
+		return cmd
+	}
+
+	if m.normalize != nil {
+		if cmd := normalizedChild(statics, name, m.normalize); cmd != nil {
+			return cmd
+		}
+	}
+
+	defer c.rlockTree()()
+
+	// find unambiguous abbreviation
+	if m.prefixMatch {
+		switch names := prefixChildren(c, name, m); len(names) {
+		case 0:
+		case 1:
+			return c.staticChildren[names[0]]
+		default:
+			return nil
+		}
+	}
+
+	// find the first param child accepting the name
+	for _, cmd := range c.paramChildren {
+		if cmd.matchParam(name) && (!m.strictParams || cmd.ParamType.valid(name)) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// exactChild returns the static subcommand with the name or an alias
+// equal to name.
+func (c *Cmd) exactChild(name string, m matcher) *Cmd {
 	// find perfect matches first
 	if cmd, ok := c.staticChildren[name]; ok {
 		return cmd
 	}
 
+	if m.ignoreCase {
+		for k, cmd := range c.staticChildren {
+			if m.equal(name, k) {
+				return cmd
+			}
+		}
+	}
+
 	// find alias matching the name
-	for _, cmd := range c.staticChildren {
+	if cmd, ok := c.aliasIndex[name]; ok {
+		return cmd
+	}
+	if m.ignoreCase {
+		for _, cmd := range c.staticChildren {
+			for _, alias := range cmd.Aliases {
+				if m.equal(name, alias) {
+					return cmd
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// normalizedChild returns the command of cmds whose name or alias
+// equals name once both are normalized.
+func normalizedChild(cmds []*Cmd, name string, normalize func(string) string) *Cmd {
+	name = normalize(name)
+	for _, cmd := range cmds {
+		if normalize(cmd.Name) == name {
+			return cmd
+		}
 		for _, alias := range cmd.Aliases {
-			if alias == name {
+			if normalize(alias) == name {
 				return cmd
 			}
 		}
 	}
+	return nil
+}
+
+// paramChild returns the param subcommand of c named name.
+func (c *Cmd) paramChild(name string) *Cmd {
+	for _, cmd := range c.paramChildren {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
 
-	// find param child
-	return c.paramChild
+// matchParam reports if value can be captured by the param command c.
+func (c *Cmd) matchParam(value string) bool {
+	return c.pattern == nil || c.pattern.MatchString(value)
 }
 
 // FindCmd finds the matching Cmd for args.
 // It returns the Cmd and the remaining args.
 func (c *Cmd) FindCmd(args []string, ctx *Context) (*Cmd, []string) {
-	var cmd *Cmd
+	return c.findCmd(args, ctx, matcher{})
+}
+
+// FindCmdPath is like FindCmd but returns all the commands matched
+// from c (excluding c) to the resolved command, including param commands
+// in the order they were matched.
+func (c *Cmd) FindCmdPath(args []string, ctx *Context) ([]*Cmd, []string) {
+	return c.findCmdPath(args, ctx, matcher{})
+}
+
+func (c *Cmd) findCmd(args []string, ctx *Context, m matcher) (*Cmd, []string) {
+	path, rest := c.findCmdPath(args, ctx, m)
+	if len(path) == 0 {
+		return nil, rest
+	}
+	return path[len(path)-1], rest
+}
+
+func (c *Cmd) findCmdPath(args []string, ctx *Context, m matcher) ([]*Cmd, []string) {
+	var path []*Cmd
 	_c := c
 
 	if ctx == nil {
@@ -222,10 +842,17 @@ func (c *Cmd) FindCmd(args []string, ctx *Context) (*Cmd, []string) {
 	}
 
 	for i, arg := range args {
-		if cmd1 := findChildCmd(_c, arg); cmd1 != nil {
-			cmd = cmd1
-			_c = cmd
+		if cmd1 := findChildCmd(_c, arg, m); cmd1 != nil {
+			path = append(path, cmd1)
+			_c = cmd1
 
+			if cmd1.kind == CatchAllKind {
+				ctx.Params = append(ctx.Params, Param{
+					Key:   cmd1.Name,
+					Value: strings.Join(args[i:], spliter),
+				})
+				return path, nil
+			}
 			if cmd1.kind == ParamKind {
 				ctx.Params = append(ctx.Params, Param{
 					Key:   cmd1.Name,
@@ -235,9 +862,9 @@ func (c *Cmd) FindCmd(args []string, ctx *Context) (*Cmd, []string) {
 
 			continue
 		}
-		return cmd, args[i:]
+		return path, args[i:]
 	}
-	return cmd, nil
+	return path, nil
 }
 
 type cmdSorter []*Cmd
@@ -1,8 +1,24 @@
-package ishell
+package ishell_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/liqianrain/ishell"
+	"github.com/liqianrain/readline"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,20 +48,20 @@ func TestFindCmd(t *testing.T) {
 	cmd := newCmd("root", "")
 	cmd.AddCmd(newCmd("child1", ""))
 	cmd.AddCmd(newCmd("child2", ""))
-	res, err := cmd.FindCmd([]string{"child1"})
-	if err != nil {
+	res, _ := cmd.FindCmd([]string{"child1"}, nil)
+	if res == nil {
 		t.Fatal("finding should work")
 	}
 	assert.Equal(t, res.Name, "child1")
 
-	res, err = cmd.FindCmd([]string{"child2"})
-	if err != nil {
+	res, _ = cmd.FindCmd([]string{"child2"}, nil)
+	if res == nil {
 		t.Fatal("finding should work")
 	}
 	assert.Equal(t, res.Name, "child2")
 
-	res, err = cmd.FindCmd([]string{"child3"})
-	if err == nil {
+	res, rest := cmd.FindCmd([]string{"child3"}, nil)
+	if len(rest) == 0 {
 		t.Fatal("should not find this child!")
 	}
 	assert.Nil(t, res)
@@ -57,20 +73,20 @@ func TestFindAlias(t *testing.T) {
 	subcmd.Aliases = []string{"alias1", "alias2"}
 	cmd.AddCmd(subcmd)
 
-	res, err := cmd.FindCmd([]string{"alias1"})
-	if err != nil {
+	res, _ := cmd.FindCmd([]string{"alias1"}, nil)
+	if res == nil {
 		t.Fatal("finding alias should work")
 	}
 	assert.Equal(t, res.Name, "child1")
 
-	res, err = cmd.FindCmd([]string{"alias2"})
-	if err != nil {
+	res, _ = cmd.FindCmd([]string{"alias2"}, nil)
+	if res == nil {
 		t.Fatal("finding alias should work")
 	}
 	assert.Equal(t, res.Name, "child1")
 
-	res, err = cmd.FindCmd([]string{"alias3"})
-	if err == nil {
+	res, rest := cmd.FindCmd([]string{"alias3"}, nil)
+	if len(rest) == 0 {
 		t.Fatal("should not find this child!")
 	}
 	assert.Nil(t, res)
@@ -81,7 +97,7 @@ func TestHelpText(t *testing.T) {
 	cmd.AddCmd(newCmd("child1", "help for child1 command"))
 	cmd.AddCmd(newCmd("child2", "help for child2 command"))
 	res := cmd.HelpText()
-	expected := "\nhelp for root command\n\nCommands:\n  child1      help for child1 command\n  child2      help for child2 command\n\n"
+	expected := "\nhelp for root command\n\nUsage: root <subcommand>\n\nCommands:\n  child1      help for child1 command\n  child2      help for child2 command\n\n"
 	assert.Equal(t, res, expected)
 }
 
@@ -93,3 +109,1770 @@ func TestChildrenSortedAlphabetically(t *testing.T) {
 	assert.Equal(t, children[0].Name, "child1", "must be first")
 	assert.Equal(t, children[1].Name, "child2", "must be second")
 }
+
+func TestValidateRequiredArgs(t *testing.T) {
+	cmd := &ishell.Cmd{
+		Name: "connect",
+		Args: []ishell.Arg{
+			{Name: "host"},
+			{Name: "port", Pair: true},
+			{Name: "verbose", Optional: true},
+		},
+	}
+	assert.NoError(t, cmd.ValidateArgs([]string{"localhost", "port", "80"}))
+	assert.EqualError(t, cmd.ValidateArgs([]string{"port", "80"}), "missing required argument: host")
+	assert.EqualError(t, cmd.ValidateArgs([]string{"localhost"}), "missing required argument: port")
+	assert.EqualError(t, cmd.ValidateArgs([]string{"localhost", "port"}), "missing value for argument: port")
+}
+
+func TestTypedArgs(t *testing.T) {
+	shell := ishell.New()
+	var port int
+	var ratio float64
+	var debug bool
+	var errs []error
+	shell.AddCmd(&ishell.Cmd{
+		Name: "serve",
+		Args: []ishell.Arg{
+			{Name: "port"},
+			{Name: "ratio", Pair: true},
+			{Name: "debug", Pair: true, Optional: true},
+		},
+		Func: func(c *ishell.Context) {
+			var err error
+			port, err = c.ArgInt("port")
+			errs = append(errs, err)
+			ratio, err = c.ArgFloat("ratio")
+			errs = append(errs, err)
+			debug, err = c.ArgBool("debug")
+			errs = append(errs, err)
+		},
+	})
+	assert.NoError(t, shell.Process("serve", "8080", "ratio", "0.5", "debug", "true"))
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Equal(t, 8080, port)
+	assert.Equal(t, 0.5, ratio)
+	assert.True(t, debug)
+
+	errs = nil
+	assert.NoError(t, shell.Process("serve", "http", "ratio", "1"))
+	assert.EqualError(t, errs[0], `invalid int value "http" for argument port`)
+	assert.NoError(t, errs[1])
+	assert.EqualError(t, errs[2], "missing argument: debug")
+}
+
+func TestArgDefaults(t *testing.T) {
+	shell := ishell.New()
+	var values map[string]string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "serve",
+		Args: []ishell.Arg{
+			{Name: "host", Optional: true, Default: "localhost"},
+			{Name: "port", Pair: true, Optional: true, Default: "80"},
+		},
+		Func: func(c *ishell.Context) {
+			values = c.NamedArgs
+		},
+	})
+	assert.NoError(t, shell.Process("serve"))
+	assert.Equal(t, map[string]string{"host": "localhost", "port": "80"}, values)
+
+	assert.NoError(t, shell.Process("serve", "example.com", "port", ""))
+	assert.Equal(t, map[string]string{"host": "example.com", "port": ""}, values)
+}
+
+func TestVariadicArgs(t *testing.T) {
+	shell := ishell.New()
+	var sources []string
+	var dest string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "cp",
+		Args: []ishell.Arg{
+			{Name: "dest"},
+			{Name: "sources", Variadic: true},
+		},
+		Func: func(c *ishell.Context) {
+			dest, _ = c.ArgValue("dest")
+			sources = c.ArgList("sources")
+		},
+	})
+	assert.NoError(t, shell.Process("cp", "dir", "a", "b", "c"))
+	assert.Equal(t, "dir", dest)
+	assert.Equal(t, []string{"a", "b", "c"}, sources)
+	assert.EqualError(t, shell.Process("cp", "dir"), "missing required argument: sources")
+
+	assert.Panics(t, func() {
+		shell.AddCmd(&ishell.Cmd{
+			Name: "bad",
+			Args: []ishell.Arg{{Name: "files", Variadic: true}, {Name: "dest"}},
+		})
+	})
+}
+
+func TestArgValidate(t *testing.T) {
+	port := func(v string) error {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			return errors.New("must be between 1 and 65535")
+		}
+		return nil
+	}
+	cmd := &ishell.Cmd{
+		Name: "listen",
+		Args: []ishell.Arg{
+			{Name: "host"},
+			{Name: "port", Validate: port},
+		},
+	}
+	assert.NoError(t, cmd.ValidateArgs([]string{"localhost", "8080"}))
+	assert.EqualError(t, cmd.ValidateArgs([]string{"localhost", "0"}), "invalid value for port: must be between 1 and 65535")
+	assert.EqualError(t, cmd.ValidateArgs([]string{}), "missing required argument: host")
+}
+
+func TestArgSeparator(t *testing.T) {
+	shell := ishell.New()
+	var target, count string
+	var rest []string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "run",
+		Args: []ishell.Arg{
+			{Name: "count", Pair: true, Optional: true},
+			{Name: "target"},
+			{Name: "args", Variadic: true, Optional: true},
+		},
+		Func: func(c *ishell.Context) {
+			target, _ = c.ArgValue("target")
+			count, _ = c.ArgValue("count")
+			rest = c.ArgList("args")
+		},
+	})
+
+	assert.NoError(t, shell.Process("run", "count", "2", "--", "ls", "count=3", "-la"))
+	assert.Equal(t, "2", count)
+	assert.Equal(t, "ls", target)
+	assert.Equal(t, []string{"count=3", "-la"}, rest)
+
+	shell.SetArgSeparator("")
+	assert.NoError(t, shell.Process("run", "--", "ls"))
+	assert.Equal(t, "--", target)
+}
+
+func TestArgChoices(t *testing.T) {
+	cmd := &ishell.Cmd{
+		Name: "log",
+		Args: []ishell.Arg{
+			{Name: "level", Pair: true, Optional: true, Choices: []string{"debug", "info", "warn"}},
+		},
+	}
+	assert.NoError(t, cmd.ValidateArgs([]string{"level", "info"}))
+	assert.NoError(t, cmd.ValidateArgs(nil))
+	assert.EqualError(t, cmd.ValidateArgs([]string{"level=trace"}), "invalid choice for level: must be one of debug, info, warn")
+}
+
+func TestArgCountBounds(t *testing.T) {
+	cmd := &ishell.Cmd{Name: "get", MinArgs: 1, MaxArgs: 3}
+	assert.NoError(t, cmd.ValidateArgCount(1))
+	assert.NoError(t, cmd.ValidateArgCount(3))
+	assert.EqualError(t, cmd.ValidateArgCount(4), `"get" accepts between 1 and 3 arguments, got 4`)
+	assert.EqualError(t, cmd.ValidateArgs(nil), `"get" accepts between 1 and 3 arguments, got 0`)
+
+	cmd.MaxArgs = -1
+	assert.NoError(t, cmd.ValidateArgCount(10))
+	assert.EqualError(t, cmd.ValidateArgCount(0), `"get" accepts at least 1 arguments, got 0`)
+
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{Name: "some", MinArgs: 2, Func: func(c *ishell.Context) {}})
+	assert.EqualError(t, shell.Process("some", "a"), `"some" accepts at least 2 arguments, got 1`)
+	assert.NoError(t, shell.Process("some", "a", "b", "c"))
+	shell.AddCmd(&ishell.Cmd{Name: "one", MinArgs: 1, MaxArgs: 1, Func: func(c *ishell.Context) {}})
+	assert.EqualError(t, shell.Process("one", "a", "b"), `"one" accepts 1 arguments, got 2`)
+	assert.NoError(t, shell.Process("one", "a"))
+	assert.Panics(t, func() {
+		shell.AddCmd(&ishell.Cmd{Name: "bad", MinArgs: 2, MaxArgs: 1})
+	})
+}
+
+func TestParamType(t *testing.T) {
+	shell := ishell.New()
+	var ids []int
+	var names []string
+	shell.AddCmd(&ishell.Cmd{Name: "users"})
+	shell.AddCmd(&ishell.Cmd{
+		Name:      "users/:id",
+		ParamType: ishell.ParamInt,
+		Func: func(c *ishell.Context) {
+			id, err := c.ParamInt("id")
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			ids = append(ids, id)
+		},
+	})
+	shell.AddCmd(&ishell.Cmd{
+		Name: "users/:name",
+		Func: func(c *ishell.Context) {
+			name, _ := c.Param("name")
+			names = append(names, name)
+		},
+	})
+
+	assert.EqualError(t, shell.Process("users", "bob"), `invalid int value "bob" for param id`)
+	shell.SetStrictParams(true)
+	assert.NoError(t, shell.Process("users", "bob"))
+	assert.NoError(t, shell.Process("users", "42"))
+	assert.Equal(t, []int{42}, ids)
+	assert.Equal(t, []string{"bob"}, names)
+
+	c := &ishell.Context{}
+	_, err := c.ParamInt("id")
+	assert.EqualError(t, err, "missing param: id")
+	assert.Equal(t, "uuid", ishell.ParamUUID.String())
+}
+
+func TestAliasNormalizer(t *testing.T) {
+	shell := ishell.New()
+	var ran []string
+	shell.AddCmd(&ishell.Cmd{
+		Name:    "remove",
+		Aliases: []string{"rm"},
+		Func:    func(c *ishell.Context) { ran = append(ran, c.Cmd.Name) },
+	})
+	assert.Error(t, shell.Process("r-m"))
+
+	shell.SetAliasNormalizer(func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "-", ""))
+	})
+	assert.NoError(t, shell.Process("rm"))
+	assert.NoError(t, shell.Process("RM"))
+	assert.NoError(t, shell.Process("r-m"))
+	assert.NoError(t, shell.Process("Re-Move"))
+	assert.Equal(t, []string{"remove", "remove", "remove", "remove"}, ran)
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	shell := ishell.New()
+	var ran []string
+	shell.AddCmd(&ishell.Cmd{
+		Name:    "List",
+		Aliases: []string{"ls"},
+		Func:    func(c *ishell.Context) { ran = append(ran, c.Cmd.Name) },
+	})
+	assert.Error(t, shell.Process("LIST"))
+
+	shell.SetCaseInsensitive(true)
+	assert.NoError(t, shell.Process("LIST"))
+	assert.NoError(t, shell.Process("list"))
+	assert.NoError(t, shell.Process("LS"))
+	assert.Equal(t, []string{"List", "List", "List"}, ran)
+}
+
+func TestDidYouMean(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{Name: "status", Func: func(c *ishell.Context) {}})
+	shell.AddCmd(&ishell.Cmd{Name: "remove", Aliases: []string{"rm"}, Func: func(c *ishell.Context) {}})
+	assert.EqualError(t, shell.Process("stauts"), `unknown command "stauts", did you mean "status"?`)
+	assert.EqualError(t, shell.Process("rn"), `unknown command "rn", did you mean "rm"?`)
+
+	shell.AddCmd(&ishell.Cmd{Name: "debug", Hidden: true, Func: func(c *ishell.Context) {}})
+	shell.AddCmd(&ishell.Cmd{Name: "deploy", Enabled: func() bool { return false }, Func: func(c *ishell.Context) {}})
+	assert.EqualError(t, shell.Process("debg"), "incorrect input, try 'help'")
+	assert.EqualError(t, shell.Process("deplo"), "incorrect input, try 'help'")
+	assert.EqualError(t, shell.Process("something"), "incorrect input, try 'help'")
+
+	shell.SetSuggestDistance(0)
+	assert.EqualError(t, shell.Process("stauts"), "incorrect input, try 'help'")
+}
+
+func TestPrefixMatch(t *testing.T) {
+	shell := ishell.New()
+	var ran string
+	for _, name := range []string{"status", "stop", "start"} {
+		shell.AddCmd(&ishell.Cmd{Name: name, Func: func(c *ishell.Context) { ran = c.Cmd.Name }})
+	}
+	assert.Error(t, shell.Process("stat"))
+
+	shell.SetAllowPrefixMatch(true)
+	assert.NoError(t, shell.Process("stat"))
+	assert.Equal(t, "status", ran)
+	assert.NoError(t, shell.Process("sto"))
+	assert.Equal(t, "stop", ran)
+	assert.EqualError(t, shell.Process("st"), `ambiguous command "st", could be start, status, stop`)
+}
+
+func TestAddCmdConflicts(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(&ishell.Cmd{Name: "remove", Aliases: []string{"rm"}})
+	assert.True(t, cmd.HasChild("remove"))
+	assert.True(t, cmd.HasChild("rm"))
+	assert.False(t, cmd.HasChild("del"))
+
+	assert.PanicsWithValue(t, "command 'remove' is already registered", func() {
+		cmd.AddCmd(newCmd("remove", ""))
+	})
+	assert.PanicsWithValue(t, "command name 'rm' conflicts with an alias of 'remove'", func() {
+		cmd.AddCmd(newCmd("rm", ""))
+	})
+	assert.PanicsWithValue(t, "alias 'rm' of 'delete' conflicts with an alias of 'remove'", func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "delete", Aliases: []string{"rm"}})
+	})
+	assert.PanicsWithValue(t, "alias 'remove' of 'delete' conflicts with command 'remove'", func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "delete", Aliases: []string{"remove"}})
+	})
+}
+
+func TestParamPattern(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("users/new", ""))
+	cmd.AddCmd(&ishell.Cmd{Name: "users/:id", Pattern: `[0-9]+`})
+
+	ctx := &ishell.Context{}
+	res, _ := cmd.FindCmd([]string{"users", "42"}, ctx)
+	assert.Equal(t, "id", res.Name)
+	assert.Equal(t, []ishell.Param{{Key: "id", Value: "42"}}, ctx.Params)
+
+	res, _ = cmd.FindCmd([]string{"users", "new"}, nil)
+	assert.Equal(t, "new", res.Name)
+
+	res, rest := cmd.FindCmd([]string{"users", "abc"}, nil)
+	assert.Equal(t, "users", res.Name)
+	assert.Equal(t, []string{"abc"}, rest)
+
+	assert.Panics(t, func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "tags/:tag", Pattern: `[a-z`})
+	})
+}
+
+func TestMultipleParamChildren(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(&ishell.Cmd{Name: "get/:id", Pattern: `[0-9]+`})
+	cmd.AddCmd(&ishell.Cmd{Name: "get/:name"})
+
+	ctx := &ishell.Context{}
+	res, _ := cmd.FindCmd([]string{"get", "7"}, ctx)
+	assert.Equal(t, "id", res.Name)
+	assert.Equal(t, []ishell.Param{{Key: "id", Value: "7"}}, ctx.Params)
+
+	ctx = &ishell.Context{}
+	res, _ = cmd.FindCmd([]string{"get", "bob"}, ctx)
+	assert.Equal(t, "name", res.Name)
+	assert.Equal(t, []ishell.Param{{Key: "name", Value: "bob"}}, ctx.Params)
+
+	get, _ := cmd.FindCmd([]string{"get"}, nil)
+	assert.Len(t, get.Children(), 2)
+	get.DeleteCmd(":id")
+	assert.Len(t, get.Children(), 1)
+	res, _ = cmd.FindCmd([]string{"get", "7"}, nil)
+	assert.Equal(t, "name", res.Name)
+}
+
+func TestFindCmdPath(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("users/:id/delete", ""))
+
+	path, rest := cmd.FindCmdPath([]string{"users", "42", "delete", "now"}, nil)
+	var names []string
+	for _, c := range path {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"users", "id", "delete"}, names)
+	assert.Equal(t, []string{"now"}, rest)
+
+	path, rest = cmd.FindCmdPath([]string{"groups"}, nil)
+	assert.Empty(t, path)
+	assert.Equal(t, []string{"groups"}, rest)
+}
+
+func TestHooks(t *testing.T) {
+	shell := ishell.New()
+	var calls []string
+	record := func(name string) func(*ishell.Context) {
+		return func(c *ishell.Context) { calls = append(calls, name+":"+c.Cmd.Name) }
+	}
+	shell.Before(record("shell-before"))
+	shell.After(record("shell-after"))
+	users := &ishell.Cmd{Name: "users", Before: record("users-before"), After: record("users-after")}
+	users.AddCmd(&ishell.Cmd{Name: "delete", Before: record("delete-before"), After: record("delete-after"), Func: record("func")})
+	shell.AddCmd(users)
+
+	assert.NoError(t, shell.Process("users", "delete"))
+	assert.Equal(t, []string{
+		"shell-before:delete",
+		"users-before:delete",
+		"delete-before:delete",
+		"func:delete",
+		"delete-after:delete",
+		"users-after:delete",
+		"shell-after:delete",
+	}, calls)
+}
+
+func TestDeprecatedCmd(t *testing.T) {
+	shell := ishell.New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	ran := false
+	shell.AddCmd(&ishell.Cmd{Name: "old", Help: "old command", Deprecated: "use 'new' instead", Func: func(c *ishell.Context) { ran = true }})
+	shell.AddCmd(&ishell.Cmd{Name: "new", Help: "new command", Func: func(c *ishell.Context) {}})
+
+	assert.NoError(t, shell.Process("old"))
+	assert.True(t, ran)
+	assert.Equal(t, "Warning: command \"old\" is deprecated, use 'new' instead\n", out.String())
+	assert.NotContains(t, shell.HelpText(), "old command")
+
+	shell.SetVerboseHelp(true)
+	assert.Contains(t, shell.HelpText(), "old command (deprecated)")
+}
+
+func TestAuthorize(t *testing.T) {
+	shell := ishell.New()
+	admin := false
+	ran := false
+	shell.AddCmd(&ishell.Cmd{
+		Name: "shutdown",
+		Authorize: func(c *ishell.Context) error {
+			if !admin {
+				return errors.New("permission denied")
+			}
+			return nil
+		},
+		Args: []ishell.Arg{{Name: "delay"}},
+		Func: func(c *ishell.Context) { ran = true },
+	})
+	assert.EqualError(t, shell.Process("shutdown"), "permission denied")
+	assert.False(t, ran)
+
+	admin = true
+	assert.NoError(t, shell.Process("shutdown", "now"))
+	assert.True(t, ran)
+}
+
+func TestHelpTextCategories(t *testing.T) {
+	cmd := newCmd("root", "help for root command")
+	cmd.AddCmd(newCmd("child1", "help for child1 command"))
+	cmd.AddCmd(&ishell.Cmd{Name: "connect", Help: "connect to host", Category: "Network"})
+	cmd.AddCmd(&ishell.Cmd{Name: "ping", Help: "ping host", Category: "Network"})
+	cmd.AddCmd(&ishell.Cmd{Name: "ls", Help: "list files", Category: "Files"})
+	expected := "\nhelp for root command\n\nUsage: root <subcommand>\n\nCommands:\n  child1      help for child1 command\n\n" +
+		"Files:\n  ls      list files\n\n" +
+		"Network:\n  connect      connect to host\n  ping         ping host\n\n"
+	assert.Equal(t, expected, cmd.HelpText())
+
+	shell := ishell.New()
+	shell.SetRootCmd(cmd)
+	shell.SetCategoryOrder([]string{"Network"})
+	assert.Regexp(t, "(?s)Network:.*Files:", shell.HelpText())
+}
+
+func TestHelpTextExamples(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(&ishell.Cmd{
+		Name:     "get",
+		Help:     "get a key",
+		Args:     []ishell.Arg{{Name: "key"}},
+		Examples: []string{"get user", `get "a b"`},
+	})
+	cmd.AddCmd(newCmd("get/all", "get all keys"))
+	res, _ := cmd.FindCmd([]string{"get"}, nil)
+	assert.Equal(t, "\nget a key\n\nUsage: root get <key>\nUsage: root get <subcommand>\n"+
+		"\nArguments:\n  <key>      \n"+
+		"\nExamples:\n  get user\n  get \"a b\"\n"+
+		"\nCommands:\n  all      get all keys\n\n", res.HelpText())
+}
+
+func TestHelpTextUsage(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(&ishell.Cmd{
+		Name: "users/:id/copy",
+		Help: "copy user",
+		Args: []ishell.Arg{
+			{Name: "dest", Help: "destination"},
+			{Name: "mode", Pair: true, Help: "copy mode"},
+			{Name: "force", Pair: true, Optional: true},
+			{Name: "note", Optional: true},
+			{Name: "tags", Variadic: true, Optional: true},
+		},
+		Func: func(c *ishell.Context) {},
+	})
+	res, _ := cmd.FindCmd([]string{"users", "1", "copy"}, nil)
+	assert.Equal(t, "\ncopy user\n\nUsage: root users <id> copy <dest> mode <value> [force <value>] [note] [tags...]\n"+
+		"\nArguments:\n  <dest>               destination\n  mode <value>         copy mode\n  [force <value>]      \n  [note]               \n  [tags...]            \n", res.HelpText())
+
+	res, _ = cmd.FindCmd([]string{"users"}, nil)
+	assert.Equal(t, "\nUsage: root users <subcommand>\n\nAvailable subcommands:\n  id      \n\n", res.HelpText())
+
+	ping := &ishell.Cmd{Name: "ping", Func: func(c *ishell.Context) {}}
+	assert.Equal(t, "\nping has no help\n\nUsage: ping\n", ping.HelpText())
+}
+
+func TestMarshalJSON(t *testing.T) {
+	cmd := newCmd("", "")
+	cmd.AddCmd(&ishell.Cmd{Name: "users", Help: "manage users", Aliases: []string{"u"}})
+	cmd.AddCmd(&ishell.Cmd{
+		Name: "users/:id",
+		Help: "show user",
+		Args: []ishell.Arg{{Name: "verbose", Optional: true}},
+	})
+	b, err := json.Marshal(cmd)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "", "kind": "static",
+		"children": [{
+			"name": "users", "kind": "static", "aliases": ["u"], "help": "manage users",
+			"children": [{
+				"name": ":id", "kind": "param", "help": "show user",
+				"args": [{"name": "verbose", "optional": true}]
+			}]
+		}]
+	}`, string(b))
+}
+
+func TestGenBashCompletion(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{Name: "users/new", Aliases: []string{"n"}})
+	shell.AddCmd(&ishell.Cmd{Name: "users/:id/delete", Args: []ishell.Arg{{Name: "force"}}})
+	var b bytes.Buffer
+	assert.NoError(t, shell.GenBashCompletion(&b))
+	script := b.String()
+	assert.Contains(t, script, "    \"\")\n        COMPREPLY=($(compgen -W \"clear exit help users\" -- \"${cur}\"))\n")
+	assert.Contains(t, script, "    /\"users\")\n        COMPREPLY=($(compgen -W \"new n\" -- \"${cur}\"))\n")
+	assert.Contains(t, script, "    /\"users\"/*/\"delete\")\n        COMPREPLY=($(compgen -W \"force\" -- \"${cur}\"))\n")
+	assert.Less(t, strings.Index(script, `/"users"/"new")`), strings.Index(script, `/"users"/*)`))
+}
+
+func TestCmdTimeout(t *testing.T) {
+	shell := ishell.New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	var cause error
+	shell.AddCmd(&ishell.Cmd{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Func: func(c *ishell.Context) {
+			<-c.Ctx.Done()
+			cause = c.Ctx.Err()
+		},
+	})
+	var fast context.Context
+	shell.AddCmd(&ishell.Cmd{
+		Name: "fast",
+		Func: func(c *ishell.Context) { fast = c.Ctx },
+	})
+
+	assert.NoError(t, shell.Process("slow"))
+	assert.Equal(t, context.DeadlineExceeded, cause)
+	assert.Equal(t, "Warning: command \"slow\" timed out after 10ms\n", out.String())
+
+	assert.NoError(t, shell.Process("fast"))
+	assert.Equal(t, context.Canceled, fast.Err())
+}
+
+func TestConcurrentAddFind(t *testing.T) {
+	cmd := newCmd("root", "")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cmd.AddCmd(newCmd(fmt.Sprintf("cmd%d/sub%d", i, j), ""))
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cmd.FindCmd([]string{fmt.Sprintf("cmd%d", i), fmt.Sprintf("sub%d", j)}, nil)
+				cmd.Children()
+				_ = cmd.HelpText()
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, cmd.Children(), 8)
+	res, _ := cmd.FindCmd([]string{"cmd3", "sub49"}, nil)
+	assert.Equal(t, "sub49", res.Name)
+
+	shell := ishell.New()
+	shell.SetOut(io.Discard)
+	run := func(c *ishell.Context) {}
+	shell.AddCmd(&ishell.Cmd{Name: "a", Func: run})
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		for j := 0; j < 500; j++ {
+			shell.AddCmd(&ishell.Cmd{Name: fmt.Sprintf("a/x%d", j), Func: run})
+		}
+	}()
+	for j := 0; j < 500; j++ {
+		assert.NoError(t, shell.Process("a"))
+		shell.Complete("a x")
+	}
+	<-added
+	assert.Len(t, shell.Complete("a x"), 500)
+}
+
+func TestCallbacksAddCmd(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(io.Discard)
+	shell.SetAliasNormalizer(func(s string) string {
+		shell.AddCmd(&ishell.Cmd{Name: "normalized/" + s})
+		return s
+	})
+	shell.AddCmd(&ishell.Cmd{
+		Name: "group/shown",
+		Enabled: func() bool {
+			shell.AddCmd(&ishell.Cmd{Name: "group/later"})
+			return true
+		},
+	})
+	assert.Error(t, shell.Process("unknown"))
+	_, err := shell.HelpFor([]string{"normalized", "unknown"})
+	assert.NoError(t, err)
+
+	_, err = shell.HelpFor([]string{"group"})
+	assert.NoError(t, err)
+	_, err = shell.HelpFor([]string{"group", "later"})
+	assert.NoError(t, err)
+}
+
+func TestStats(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{Name: "users/:id", Func: func(c *ishell.Context) {}})
+	assert.NoError(t, shell.Process("users", "1"))
+	assert.Empty(t, shell.Stats())
+
+	shell.EnableStats(true)
+	assert.NoError(t, shell.Process("users", "1"))
+	assert.NoError(t, shell.Process("users", "2"))
+	stats := shell.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, 2, stats["users/:id"].Count)
+
+	shell.ResetStats()
+	assert.Empty(t, shell.Stats())
+}
+
+func TestHelpOnEmptyFunc(t *testing.T) {
+	shell := ishell.New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&ishell.Cmd{Name: "config", Help: "manage config"})
+	shell.AddCmd(&ishell.Cmd{Name: "config/get", Help: "get a value", Func: func(c *ishell.Context) {}})
+
+	assert.NoError(t, shell.Process("config"))
+	assert.Contains(t, out.String(), "get      get a value")
+
+	out.Reset()
+	shell.SetHelpOnEmptyFunc(false)
+	assert.NoError(t, shell.Process("config"))
+	assert.Empty(t, out.String())
+}
+
+func TestCommandSort(t *testing.T) {
+	shell := ishell.New()
+	root := newCmd("", "")
+	shell.SetRootCmd(root)
+	root.AddCmd(newCmd("zeta", ""))
+	root.AddCmd(newCmd(":id", ""))
+	root.AddCmd(newCmd("alpha", ""))
+	names := func() (n []string) {
+		for _, c := range root.Children() {
+			n = append(n, c.Name)
+		}
+		return
+	}
+	assert.Equal(t, []string{"alpha", "id", "zeta"}, names())
+	shell.SetCommandSort(ishell.SortRegistration)
+	assert.Equal(t, []string{"zeta", "id", "alpha"}, names())
+}
+
+func TestContextParams(t *testing.T) {
+	c := &ishell.Context{Params: []ishell.Param{
+		{Key: "org", Value: "acme"},
+		{Key: "id", Value: "1"},
+		{Key: "id", Value: "2"},
+	}}
+	v, ok := c.Param("id")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	_, ok = c.Param("name")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"org": "acme", "id": "2"}, c.ParamMap())
+}
+
+func TestCatchAllParam(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("files/*path", ""))
+
+	ctx := &ishell.Context{}
+	res, rest := cmd.FindCmd([]string{"files", "a", "b", "c"}, ctx)
+	assert.Equal(t, "path", res.Name)
+	assert.Empty(t, rest)
+	assert.Equal(t, []ishell.Param{{Key: "path", Value: "a/b/c"}}, ctx.Params)
+
+	assert.Panics(t, func() { cmd.AddCmd(newCmd("files/*path/info", "")) })
+	assert.Panics(t, func() { cmd.AddCmd(newCmd("dirs/*path/info", "")) })
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.AddCmd(newCmd("greet", ""))
+
+	var raw []string
+	shell.NotFound(func(c *ishell.Context) {
+		raw = c.RawArgs
+		if c.Args[0] != "ls" {
+			c.Unhandled()
+		}
+	})
+	assert.NoError(t, shell.Process("ls", "-l"))
+	assert.Equal(t, []string{"ls", "-l"}, raw)
+
+	err := shell.Process("gret")
+	assert.EqualError(t, err, `unknown command "gret", did you mean "greet"?`)
+}
+
+func TestEval(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	shell.SetOut(&buf)
+	shell.AddCmd(&ishell.Cmd{
+		Name: "echo",
+		Func: func(c *ishell.Context) {
+			c.Println(strings.Join(c.Args, ","))
+		},
+	})
+
+	out, err := shell.Eval(`echo a "b c"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b c\n", out)
+	assert.Empty(t, buf.String())
+
+	_, err = shell.Eval("unknown")
+	assert.Error(t, err)
+	_, err = shell.Eval(`echo "a`)
+	assert.Error(t, err)
+}
+
+func TestEvalResult(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{
+		Name: "users/:id/rename",
+		Args: []ishell.Arg{{Name: "name"}},
+		RunE: func(c *ishell.Context) error {
+			c.Print("renamed")
+			return errors.New("read-only")
+		},
+	})
+
+	r, err := shell.EvalResult("users 7 rename bob")
+	assert.EqualError(t, err, "read-only")
+	assert.Equal(t, ishell.Result{
+		Command:   "users/:id/rename",
+		Args:      []string{"bob"},
+		NamedArgs: map[string]string{"name": "bob"},
+		Params:    []ishell.Param{{Key: "id", Value: "7"}},
+		Output:    "renamed",
+		Err:       errors.New("read-only"),
+	}, r)
+
+	r, err = shell.EvalResult("users 7 rename")
+	assert.EqualError(t, err, "missing required argument: name")
+	assert.Equal(t, "", r.Command)
+}
+
+func TestContextLine(t *testing.T) {
+	shell := ishell.New()
+	var line string
+	var raw []string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "repeat",
+		Func: func(c *ishell.Context) {
+			line, raw = c.Line, c.RawArgs
+		},
+	})
+	shell.NotFound(func(c *ishell.Context) {
+		line, raw = c.Line, c.RawArgs
+	})
+
+	_, err := shell.Eval(`repeat  "a  b"   c`)
+	assert.NoError(t, err)
+	assert.Equal(t, `repeat  "a  b"   c`, line)
+
+	// Process has no line, nothing is left from the previous one
+	assert.NoError(t, shell.Process("repeat", "p"))
+	assert.Equal(t, "", line)
+	assert.Nil(t, raw)
+	assert.NoError(t, shell.Process("other", "p"))
+	assert.Equal(t, []string{"other", "p"}, raw)
+}
+
+func TestContextWriter(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	shell.SetOut(w)
+	shell.AddCmd(&ishell.Cmd{
+		Name: "tail",
+		Func: func(c *ishell.Context) {
+			fmt.Fprintf(c, "line %d\n", 1)
+			json.NewEncoder(c).Encode(map[string]int{"n": 2})
+			assert.Empty(t, buf.String())
+			assert.NoError(t, c.Flush())
+			assert.Equal(t, "line 1\n{\"n\":2}\n", buf.String())
+		},
+	})
+	assert.NoError(t, shell.Process("tail"))
+}
+
+func TestRunScript(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	shell.SetOut(&buf)
+	var lines []string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "echo",
+		Func: func(c *ishell.Context) {
+			lines = append(lines, strings.Join(c.Args, " "))
+		},
+	})
+
+	script := "# setup\n\necho a\necho b \\\n  c\nbad\necho d\n"
+	err := shell.RunScript(strings.NewReader(script))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 6:")
+	}
+	assert.Equal(t, []string{"a", "b c"}, lines)
+
+	lines = nil
+	shell.SetHaltOnError(false)
+	assert.NoError(t, shell.RunScript(strings.NewReader(script)))
+	assert.Equal(t, []string{"a", "b c", "d"}, lines)
+	assert.Contains(t, buf.String(), "Error:")
+}
+
+func TestParseError(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.AddCmd(newCmd("echo", ""))
+
+	_, err := shell.Eval(`echo "unterminated`)
+	assert.EqualError(t, err, "parse error: unterminated quote")
+}
+
+func TestComplete(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(newCmd("user/add", ""))
+	shell.AddCmd(newCmd("user/list", ""))
+
+	words := func(s []ishell.Suggestion) (w []string) {
+		for _, v := range s {
+			w = append(w, v.Word)
+		}
+		return
+	}
+	assert.Equal(t, []string{"user"}, words(shell.Complete("us")))
+	assert.Equal(t, []string{"add", "list"}, words(shell.Complete("user ")))
+	assert.Equal(t, []string{"list"}, words(shell.Complete("user l")))
+}
+
+func TestAddAlias(t *testing.T) {
+	shell := ishell.New()
+	var got []string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "ls",
+		Func: func(c *ishell.Context) {
+			got = c.Args
+		},
+	})
+	shell.AddAlias("ll", `ls -l "a b"`)
+	shell.AddAlias("ls", "ls -a")
+	assert.NoError(t, shell.Process("ll", "x"))
+	assert.Equal(t, []string{"-a", "-l", "a b", "x"}, got)
+
+	shell.AddAlias("loop", "loop2")
+	shell.AddAlias("loop2", "loop")
+	assert.Error(t, shell.Process("loop"))
+
+	assert.Equal(t, map[string]string{"ll": `ls -l "a b"`, "ls": "ls -a", "loop": "loop2", "loop2": "loop"}, shell.Aliases())
+}
+
+func TestHelpFor(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(newCmd("users/delete", "delete a user"))
+	shell.AddCmd(newCmd("users/:id/show", "show a user"))
+
+	help, err := shell.HelpFor([]string{"users", "delete"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "delete a user")
+
+	help, err = shell.HelpFor([]string{"users", ":id", "show"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "show a user")
+
+	help, err = shell.HelpFor([]string{"users", "42", "show"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "show a user")
+
+	help, err = shell.HelpFor([]string{"users", ":id", "nope"})
+	assert.EqualError(t, err, `unknown subcommand "nope" of "users <id>"`)
+	assert.Contains(t, help, "Usage: users <id> <subcommand>")
+
+	_, err = shell.HelpFor([]string{"nope"})
+	assert.Error(t, err)
+}
+
+func TestRequiredArgAfterOptional(t *testing.T) {
+	cmd := newCmd("root", "")
+	assert.PanicsWithValue(t, "required argument 'b' of 'bad' follows optional argument 'a'", func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "bad", Args: []ishell.Arg{{Name: "a", Optional: true}, {Name: "b"}}})
+	})
+	// the intermediate commands of a command failing to be added are not
+	// created
+	assert.Panics(t, func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "tools/bad", Args: []ishell.Arg{{Name: "a", Optional: true}, {Name: "b"}}})
+	})
+	assert.False(t, cmd.HasChild("tools"))
+	assert.NotPanics(t, func() {
+		cmd.AddCmd(&ishell.Cmd{Name: "good", Args: []ishell.Arg{{Name: "a", Optional: true}, {Name: "b", Pair: true}}})
+	})
+}
+
+func TestInlinePairArgs(t *testing.T) {
+	shell := ishell.New()
+	var got map[string]string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "serve",
+		Args: []ishell.Arg{{Name: "port", Pair: true}, {Name: "dir"}},
+		Func: func(c *ishell.Context) {
+			got = c.NamedArgs
+		},
+	})
+	assert.NoError(t, shell.Process("serve", "port=8080", "a=b"))
+	assert.Equal(t, map[string]string{"port": "8080", "dir": "a=b"}, got)
+	assert.NoError(t, shell.Process("serve", "port", "80", "www"))
+	assert.Equal(t, map[string]string{"port": "80", "dir": "www"}, got)
+}
+
+func TestDeleteCmdPath(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("a/b/c", ""))
+	cmd.AddCmd(newCmd("x/:id/y", ""))
+	cmd.AddCmd(&ishell.Cmd{Name: "x/z", Func: func(c *ishell.Context) {}})
+
+	cmd.DeleteCmd("a/b/c")
+	assert.False(t, cmd.HasChild("a"))
+
+	cmd.DeleteCmd("x/:id/y")
+	assert.True(t, cmd.HasChild("x"))
+	x, _ := cmd.FindCmd([]string{"x"}, nil)
+	assert.False(t, x.HasChild(":id"))
+	assert.True(t, x.HasChild("z"))
+
+	cmd.AddCmd(newCmd("x/z/w", ""))
+	cmd.DeleteCmd("x/z/w")
+	assert.True(t, x.HasChild("z"))
+}
+
+func TestWalk(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("users/:id/show", ""))
+	cmd.AddCmd(newCmd("users/list", ""))
+	cmd.AddCmd(newCmd("groups/list", ""))
+
+	var paths []string
+	err := cmd.Walk(func(path []string, c *ishell.Cmd) error {
+		paths = append(paths, strings.Join(path, " "))
+		if c.Name == "groups" {
+			return ishell.SkipChildren
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"groups", "users", "users :id", "users :id show", "users list"}, paths)
+
+	stop := errors.New("stop")
+	err = cmd.Walk(func(path []string, c *ishell.Cmd) error {
+		return stop
+	})
+	assert.Equal(t, stop, err)
+}
+
+func TestSetHelpCommand(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.AddCmd(&ishell.Cmd{Name: "run", Help: "run it", Func: func(c *ishell.Context) {}})
+
+	shell.SetHelpCommand("ayuda")
+	out, err := shell.Eval("ayuda run")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "run it")
+	out, err = shell.Eval("run ayuda")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "run it")
+	_, err = shell.Eval("help")
+	assert.Error(t, err)
+
+	shell.DisableAutoHelp()
+	_, err = shell.Eval("ayuda")
+	assert.Error(t, err)
+	assert.NotPanics(t, func() { shell.AddCmd(newCmd("help", "")) })
+}
+
+func TestLongHelpFunc(t *testing.T) {
+	cmd := newCmd("root", "")
+	level := "info"
+	cmd.AddCmd(&ishell.Cmd{
+		Name:     "log",
+		Help:     "set log level",
+		LongHelp: "static",
+		LongHelpFunc: func(c *ishell.Cmd) string {
+			return "current level is " + level + ", " + strconv.Itoa(len(c.Children())) + " subcommands"
+		},
+	})
+	cmd.AddCmd(newCmd("log/debug", "debug level"))
+
+	res, _ := cmd.FindCmd([]string{"log"}, nil)
+	assert.Contains(t, res.HelpText(), "current level is info, 1 subcommands")
+	level = "debug"
+	assert.Contains(t, res.HelpText(), "current level is debug")
+	assert.Contains(t, res.HelpText(), "debug level")
+}
+
+func TestClearScreenNotTerminal(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	shell.SetOut(&buf)
+	assert.NoError(t, shell.ClearScreen())
+	assert.Empty(t, buf.String())
+}
+
+func TestPanicRecovered(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	shell.SetOut(&buf)
+	after := false
+	shell.AddCmd(&ishell.Cmd{
+		Name:  "boom",
+		Func:  func(c *ishell.Context) { panic("oops") },
+		After: func(c *ishell.Context) { after = true },
+	})
+
+	err := shell.Process("boom")
+	assert.EqualError(t, err, "panic: oops")
+	assert.True(t, after)
+	assert.True(t, strings.HasPrefix(buf.String(), "panic: oops\n\tgithub.com/liqianrain/ishell_test.TestPanicRecovered"), buf.String())
+	assert.Contains(t, buf.String(), "command_test.go")
+
+	shell.AddCmd(&ishell.Cmd{
+		Name:   "early",
+		Before: func(c *ishell.Context) { panic("before") },
+		Func:   func(c *ishell.Context) {},
+	})
+	shell.AddCmd(&ishell.Cmd{
+		Name:      "guarded",
+		Authorize: func(c *ishell.Context) error { panic("authorize") },
+		Func:      func(c *ishell.Context) { t.Error("unauthorized command ran") },
+	})
+	assert.EqualError(t, shell.Process("early"), "panic: before")
+	assert.EqualError(t, shell.Process("guarded"), "panic: authorize")
+
+	// a panic while completing hides the command
+	shell.SetHideUnauthorized(true)
+	assert.Empty(t, shell.Complete("guar"))
+	assert.Len(t, shell.Complete("ear"), 1)
+
+	var recovered interface{}
+	shell.SetPanicHandler(func(c *ishell.Context, r interface{}) {
+		recovered = r
+	})
+	assert.NoError(t, shell.Process("boom"))
+	assert.Equal(t, "oops", recovered)
+	assert.EqualError(t, shell.Process("guarded"), `authorization of "guarded" panicked`)
+	assert.Equal(t, "authorize", recovered)
+}
+
+func TestArgHistory(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{
+		Name: "connect",
+		Args: []ishell.Arg{{Name: "host", HistoryKey: "hosts"}},
+		Func: func(c *ishell.Context) {},
+	})
+	for _, host := range []string{"db1", "web1", "db2", "db1"} {
+		assert.NoError(t, shell.Process("connect", host))
+	}
+
+	var words []string
+	for _, s := range shell.Complete("connect ") {
+		words = append(words, s.Word)
+	}
+	assert.Equal(t, []string{"db1", "db2", "web1", "host"}, words)
+
+	shell.ClearArgHistory("hosts")
+	assert.Len(t, shell.Complete("connect "), 1)
+}
+
+func TestExitCommand(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.SetExitCommand("quit")
+	assert.Error(t, shell.Process("exit"))
+
+	asked := false
+	shell.SetExitConfirm(func() bool {
+		asked = true
+		return false
+	})
+	assert.NoError(t, shell.Process("quit"))
+	assert.True(t, asked)
+}
+
+func TestMultiWordName(t *testing.T) {
+	shell := ishell.New()
+	ran := false
+	shell.AddCmd(&ishell.Cmd{Name: "show routes", Func: func(c *ishell.Context) { ran = true }})
+	assert.NoError(t, shell.Process("show", "routes"))
+	assert.True(t, ran)
+
+	var words []string
+	for _, s := range shell.Complete("show ") {
+		words = append(words, s.Word)
+	}
+	assert.Equal(t, []string{"routes"}, words)
+
+	shell.DeleteCmd("show routes")
+	assert.Error(t, shell.Process("show"))
+}
+
+func benchmarkTree(n int) *ishell.Cmd {
+	root := newCmd("", "")
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("cmd%04d", i)
+		root.AddCmd(&ishell.Cmd{Name: name, Aliases: []string{"a" + name}})
+	}
+	return root
+}
+
+func TestAliasesFrozen(t *testing.T) {
+	root := newCmd("", "")
+	cmd := &ishell.Cmd{Name: "remove", Aliases: []string{"rm"}}
+	root.AddCmd(cmd)
+	cmd.Aliases = []string{"del"}
+
+	found, _ := root.FindCmd([]string{"rm"}, nil)
+	assert.Equal(t, cmd, found)
+	found, _ = root.FindCmd([]string{"del"}, nil)
+	assert.Nil(t, found)
+}
+
+func BenchmarkFindCmdAlias(b *testing.B) {
+	root := benchmarkTree(2000)
+	args := []string{"acmd1999"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.FindCmd(args, nil)
+	}
+}
+
+func TestHelpTextCache(t *testing.T) {
+	cmd := newCmd("root", "")
+	cmd.AddCmd(newCmd("a", "first"))
+	text := cmd.HelpText()
+	assert.Equal(t, text, cmd.HelpText())
+
+	cmd.AddCmd(newCmd("b", "second"))
+	assert.Contains(t, cmd.HelpText(), "second")
+
+	b, _ := cmd.FindCmd([]string{"b"}, nil)
+	b.Help = "changed"
+	assert.Contains(t, cmd.HelpText(), "changed")
+	b.Hidden = true
+	assert.NotContains(t, cmd.HelpText(), "changed")
+	b.Hidden = false
+	b.Deprecated = "use a"
+	assert.NotContains(t, cmd.HelpText(), "changed")
+	b.Deprecated = ""
+	b.Category = "Other"
+	assert.Contains(t, cmd.HelpText(), "Other:")
+	cmd.Help = "the root"
+	assert.Contains(t, cmd.HelpText(), "the root")
+	b.Args = []ishell.Arg{{Name: "name", Help: "the name"}}
+	assert.Contains(t, b.HelpText(), "the name")
+
+	cmd.InvalidateHelpCache()
+	assert.Equal(t, cmd.HelpText(), cmd.HelpText())
+}
+
+func TestRunE(t *testing.T) {
+	shell := ishell.New()
+	var buf bytes.Buffer
+	shell.SetOut(&buf)
+	shell.AddCmd(&ishell.Cmd{
+		Name: "fail",
+		Func: func(c *ishell.Context) { c.Println("func") },
+		RunE: func(c *ishell.Context) error { return errors.New("failed") },
+	})
+	_, err := shell.Eval("fail")
+	assert.EqualError(t, err, "failed")
+	assert.NotContains(t, buf.String(), "func")
+
+	var handled error
+	shell.SetErrorHandler(func(err error) { handled = err })
+	shell.SetHaltOnError(false)
+	assert.NoError(t, shell.RunScript(strings.NewReader("fail\n")))
+	assert.EqualError(t, handled, "failed")
+}
+
+func TestContextDone(t *testing.T) {
+	shell := ishell.New()
+	shell.SetOut(&bytes.Buffer{})
+	done := false
+	shell.AddCmd(&ishell.Cmd{
+		Name:    "wait",
+		Timeout: 10 * time.Millisecond,
+		Func: func(c *ishell.Context) {
+			<-c.Done()
+			done = true
+		},
+	})
+	assert.NoError(t, shell.Process("wait"))
+	assert.True(t, done)
+}
+
+func TestPrintPagedNotInteractive(t *testing.T) {
+	shell := ishell.New()
+	shell.AddCmd(&ishell.Cmd{
+		Name: "long",
+		Func: func(c *ishell.Context) { c.PrintPaged(strings.Repeat("line\n", 500)) },
+	})
+	out, err := shell.Eval("long")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("line\n", 500), out)
+}
+
+func TestShowPagedPagerEnv(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat is not available")
+	}
+	var out bytes.Buffer
+	shell := ishell.New()
+	shell.SetOut(&out)
+	t.Setenv("PAGER", "true")
+	assert.NoError(t, shell.ShowPaged("text\n"))
+	assert.Empty(t, out.String())
+
+	// PAGER is read again for each page, not kept as the pager
+	t.Setenv("PAGER", "cat")
+	assert.NoError(t, shell.ShowPaged("text\n"))
+	assert.Equal(t, "text\n", out.String())
+}
+
+func TestParamNameValidation(t *testing.T) {
+	cmd := newCmd("root", "")
+	assert.PanicsWithValue(t, "wildcards must be named with a non-empty name 'users/:'", func() {
+		cmd.AddCmd(newCmd("users/:", ""))
+	})
+	assert.PanicsWithValue(t, "invalid param name ':user-id' in 'users/:user-id'", func() {
+		cmd.AddCmd(newCmd("users/:user-id", ""))
+	})
+	assert.PanicsWithValue(t, "duplicate param 'id' in 'users/:id/groups/:id'", func() {
+		cmd.AddCmd(newCmd("users/:id/groups/:id", ""))
+	})
+	assert.False(t, cmd.HasChild("users"))
+
+	cmd.AddCmd(newCmd("users/:id", ""))
+	users, _ := cmd.FindCmd([]string{"users", "1"}, nil)
+	assert.PanicsWithValue(t, "duplicate param 'id' in 'groups/:id'", func() {
+		users.AddCmd(newCmd("groups/:id", ""))
+	})
+}
+
+type structCmds struct {
+	greeted []string
+
+	_ struct{} `ishell:"method=Greet,help=greet the user,alias=hi,alias=hello"`
+	_ struct{} `ishell:"method=ListAll,name=ls"`
+}
+
+func (s *structCmds) Greet(c *ishell.Context)   { s.greeted = append(s.greeted, c.Args...) }
+func (s *structCmds) ListAll(c *ishell.Context) { c.Print("all") }
+func (s *structCmds) Count() int                { return len(s.greeted) }
+
+func TestAddStruct(t *testing.T) {
+	shell := ishell.New()
+	cmds := &structCmds{}
+	shell.AddStruct(cmds)
+
+	assert.NoError(t, shell.Process("greet", "a"))
+	assert.NoError(t, shell.Process("hi", "b"))
+	assert.Equal(t, []string{"a", "b"}, cmds.greeted)
+	out, err := shell.Eval("ls")
+	assert.NoError(t, err)
+	assert.Equal(t, "all", out)
+	assert.Error(t, shell.Process("count"))
+	help, err := shell.HelpFor([]string{"greet"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "greet the user")
+
+	assert.Panics(t, func() { shell.AddStruct(42) })
+	assert.PanicsWithValue(t, "method 'Missing' of *ishell_test.badStructCmds not found", func() {
+		shell.AddStruct(&badStructCmds{})
+	})
+}
+
+type badStructCmds struct {
+	_ struct{} `ishell:"method=Missing"`
+}
+
+func TestSubShell(t *testing.T) {
+	var out bytes.Buffer
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("set a\nquit\nget\nhelp\nexit\n")),
+		Stdout: &out,
+	})
+	shell.SetOut(&out)
+	var ran []string
+	config := &ishell.Cmd{Name: "config"}
+	config.AddCmd(&ishell.Cmd{Name: "set", Help: "set a value", Func: func(c *ishell.Context) { ran = append(ran, "set "+c.Args[0]) }})
+	config.AddCmd(&ishell.Cmd{Name: "get", Func: func(c *ishell.Context) { ran = append(ran, "get") }})
+	shell.AddCmd(&ishell.Cmd{Name: "quit", Func: func(c *ishell.Context) { ran = append(ran, "quit") }})
+	shell.AddCmd(&ishell.Cmd{
+		Name: "config",
+		Func: func(c *ishell.Context) { c.StartSubShell(config, "app/config> ") },
+	})
+
+	assert.NoError(t, shell.Process("config"))
+	assert.Equal(t, []string{"set a", "get"}, ran)
+	assert.Equal(t, 1, strings.Count(out.String(), "Error:"), out.String())
+	assert.Contains(t, out.String(), "set a value")
+	assert.NoError(t, shell.Process("quit"))
+}
+
+func TestExplainResolution(t *testing.T) {
+	shell := ishell.New()
+	f := func(c *ishell.Context) {}
+	shell.AddCmd(&ishell.Cmd{Name: "users", Aliases: []string{"u"}})
+	shell.AddCmd(&ishell.Cmd{Name: "users/:id/show", Func: f})
+	shell.AddCmd(&ishell.Cmd{Name: "files/*path", Func: f})
+	shell.AddAlias("su", "users 1 show")
+
+	assert.Equal(t, `"u" -> users (alias u)
+"42" -> users/:id (param id=42)
+"show" -> users/:id/show (static)
+args: ["-v" "a b"]
+`, shell.ExplainResolution(`u 42 show -v "a b"`))
+	assert.Equal(t, `alias: ["su"] -> ["users" "1" "show"]
+"users" -> users (static)
+"1" -> users/:id (param id=1)
+"show" -> users/:id/show (static)
+`, shell.ExplainResolution("su"))
+	assert.Equal(t, `"files" -> files (static)
+"a/b" -> files/*path (catchall path=a/b)
+`, shell.ExplainResolution("files a b"))
+	assert.Equal(t, "\"users\" -> users (static)\nno function, the help is shown\n", shell.ExplainResolution("users"))
+	assert.Equal(t, "no command matched\nargs: [\"nope\"]\n", shell.ExplainResolution("nope"))
+	assert.Equal(t, "error: parse error: unterminated quote\n", shell.ExplainResolution(`users "1`))
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n\x00\xff\nb\nc\n"), 0o600))
+
+	shell := ishell.New()
+	shell.SetHistoryLimit(2)
+	shell.SetHistoryFile(path)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "b\nc\n", string(data))
+
+	shell.SetHistoryLimit(1)
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "c\n", string(data))
+
+	created := filepath.Join(t.TempDir(), "created")
+	shell.SetHistoryFile(created)
+	assert.FileExists(t, created)
+
+	// a file that cannot be opened is not fatal
+	shell.SetHistoryFile(filepath.Join(path, "missing", "history"))
+}
+
+func TestDefineIntermediateCmd(t *testing.T) {
+	shell := ishell.New()
+	var ran []string
+	run := func(c *ishell.Context) { ran = append(ran, c.Cmd.Name) }
+	shell.AddCmd(&ishell.Cmd{Name: "a/b/c", Func: run})
+	a := &ishell.Cmd{Name: "a", Help: "the a", Aliases: []string{"aa"}, Func: run}
+	shell.AddCmd(a)
+	a.AddCmd(&ishell.Cmd{Name: "d", Func: run})
+
+	assert.NoError(t, shell.Process("aa"))
+	assert.NoError(t, shell.Process("a", "b", "c"))
+	assert.NoError(t, shell.Process("a", "d"))
+	assert.Equal(t, []string{"a", "c", "d"}, ran)
+	assert.Contains(t, shell.HelpText(), "the a")
+
+	assert.PanicsWithValue(t, "command 'a' is already registered", func() {
+		shell.AddCmd(&ishell.Cmd{Name: "a"})
+	})
+}
+
+func TestDefineIntermediateParam(t *testing.T) {
+	for _, names := range [][]string{{"users/:id/show", "users/:id"}, {"users/:id", "users/:id/show"}} {
+		cmd := newCmd("root", "")
+		for _, name := range names {
+			cmd.AddCmd(&ishell.Cmd{Name: name, Help: name})
+		}
+		user, _ := cmd.FindCmd([]string{"users", "1"}, nil)
+		assert.Equal(t, "users/:id", user.Help)
+		show, _ := cmd.FindCmd([]string{"users", "1", "show"}, nil)
+		assert.Equal(t, "users/:id/show", show.Help)
+
+		assert.PanicsWithValue(t, "command ':id' is already registered", func() {
+			cmd.AddCmd(&ishell.Cmd{Name: "users/:id"})
+		})
+	}
+}
+
+func TestEmptyLineAndEOFHandlers(t *testing.T) {
+	var out bytes.Buffer
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("greet\n\n")),
+		Stdout: &out,
+	})
+	var ran, empty, eof int
+	shell.AddCmd(&ishell.Cmd{Name: "greet", Func: func(c *ishell.Context) { ran++ }})
+	shell.SetEmptyLineHandler(func() { empty++ })
+	shell.SetEOFHandler(func() bool {
+		eof++
+		return true
+	})
+	shell.Run()
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, 1, empty)
+	assert.Equal(t, 1, eof)
+}
+
+func TestPromptFunc(t *testing.T) {
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("connect\nconnect\n")),
+		Stdout: io.Discard,
+	})
+	var hosts []int
+	host := 0
+	shell.AddCmd(&ishell.Cmd{Name: "connect", Func: func(c *ishell.Context) { host++ }})
+	shell.SetPromptFunc(func() string {
+		hosts = append(hosts, host)
+		return fmt.Sprintf("app(host-%d)> ", host)
+	})
+	shell.Run()
+	assert.Equal(t, []int{0, 1, 2}, hosts)
+}
+
+func TestReadLineWithPromptInScript(t *testing.T) {
+	shell := ishell.New()
+	var names []string
+	confirmed := false
+	shell.AddCmd(&ishell.Cmd{Name: "delete", Func: func(c *ishell.Context) {
+		confirmed = c.Confirm("delete everything? ")
+	}})
+	shell.AddCmd(&ishell.Cmd{Name: "wizard", RunE: func(c *ishell.Context) error {
+		for i := 0; i < 2; i++ {
+			name, err := c.ReadLineWithPrompt("name: ")
+			if err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+		return nil
+	}})
+	err := shell.RunScript(strings.NewReader("delete\nYes\nwizard\nalice\nbob\nwizard\ncarol\n"))
+	assert.True(t, confirmed)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, names)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Contains(t, err.Error(), "line 6")
+}
+
+func TestReadLineWithPrompt(t *testing.T) {
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("delete\nno\n")),
+		Stdout: io.Discard,
+	})
+	confirmed := true
+	shell.AddCmd(&ishell.Cmd{Name: "delete", Func: func(c *ishell.Context) {
+		confirmed = c.Confirm("delete everything? ")
+	}})
+	shell.Run()
+	assert.False(t, confirmed)
+}
+
+func TestSlashPaths(t *testing.T) {
+	shell := ishell.New()
+	var got []string
+	shell.AddCmd(&ishell.Cmd{Name: "users/:id/delete", Func: func(c *ishell.Context) {
+		got = append(got, c.Params[0].Value+" "+strings.Join(c.Args, ","))
+	}})
+
+	assert.Error(t, shell.Process("users/42/delete", "now"))
+	shell.SetSlashPaths(true)
+	assert.NoError(t, shell.Process("users/42/delete", "now"))
+	assert.NoError(t, shell.Process("/users/7/", "delete", "/tmp/x"))
+	assert.Equal(t, []string{"42 now", "7 /tmp/x"}, got)
+}
+
+func TestTeeOutput(t *testing.T) {
+	shell := ishell.New()
+	var out, transcript bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&ishell.Cmd{Name: "greet", Help: "say hello", Func: func(c *ishell.Context) {
+		c.Println("hello")
+	}})
+	shell.TeeOutput(&transcript)
+
+	assert.NoError(t, shell.Process("greet"))
+	assert.NoError(t, shell.Process("help"))
+	assert.Equal(t, out.String(), transcript.String())
+	assert.Contains(t, transcript.String(), "hello\n")
+	assert.Contains(t, transcript.String(), "say hello")
+
+	// the tee is kept when the writer is replaced
+	var out2 bytes.Buffer
+	shell.SetOutputWriter(&out2)
+	transcript.Reset()
+	assert.NoError(t, shell.Process("greet"))
+	assert.Equal(t, "hello\n", out2.String())
+	assert.Equal(t, "hello\n", transcript.String())
+
+	shell.TeeOutput(nil)
+	assert.NoError(t, shell.Process("greet"))
+	assert.Equal(t, "hello\nhello\n", out2.String())
+	assert.Equal(t, "hello\n", transcript.String())
+}
+
+func TestEnabled(t *testing.T) {
+	shell := ishell.New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	connected := false
+	deployed := false
+	shell.AddCmd(&ishell.Cmd{
+		Name:    "deploy",
+		Help:    "deploy the app",
+		Enabled: func() bool { return connected },
+		Authorize: func(c *ishell.Context) error {
+			return errors.New("not authorized")
+		},
+		Func: func(c *ishell.Context) { deployed = true },
+	})
+
+	err := shell.Process("deploy")
+	assert.EqualError(t, err, `command "deploy" is disabled`)
+	assert.False(t, deployed)
+	assert.Contains(t, shell.HelpText(), "deploy the app (disabled)")
+
+	// Authorize still applies once enabled
+	connected = true
+	assert.EqualError(t, shell.Process("deploy"), "not authorized")
+	assert.NotContains(t, shell.HelpText(), "(disabled)")
+
+	connected = false
+	shell.SetHideDisabled(true)
+	assert.NotContains(t, shell.HelpText(), "deploy")
+}
+
+func TestBindKey(t *testing.T) {
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("gr\x0e\ngr!eet\n")),
+		Stdout: io.Discard,
+	})
+	count := 0
+	shell.AddCmd(&ishell.Cmd{Name: "greet", Func: func(c *ishell.Context) { count++ }})
+	shell.BindKey(readline.CharNext, readline.CharTab)
+	shell.BindKey('!', 0)
+	shell.Run()
+	assert.Equal(t, 2, count)
+}
+
+func TestLint(t *testing.T) {
+	shell := ishell.New()
+	shell.DeleteCmd("help")
+	shell.DeleteCmd("clear")
+	shell.DeleteCmd("exit")
+	run := func(c *ishell.Context) {}
+	for _, name := range []string{"users/new", "users/list", "users/search", "users/:id", "users/:name"} {
+		shell.AddCmd(&ishell.Cmd{Name: name, Func: run})
+	}
+	shell.AddCmd(&ishell.Cmd{Name: "orders/:id", Pattern: "[0-9]+", Func: run})
+	shell.AddCmd(&ishell.Cmd{Name: "orders/:ref", Func: run})
+	shell.AddCmd(&ishell.Cmd{Name: "todo"})
+	shell.AddCmd(&ishell.Cmd{Name: "pages/:n", ParamType: ishell.ParamInt, Func: run})
+	shell.AddCmd(&ishell.Cmd{Name: "pages/:slug", Func: run})
+
+	assert.ElementsMatch(t, []string{
+		"users/:name: never matched, users/:id captures any word first",
+		"users/:id: captures any word, including the typos of its 3 static siblings",
+		"todo: no function and no subcommands",
+		"pages/:slug: never matched, pages/:n captures any word first",
+	}, shell.Lint())
+
+	shell.SetStrictParams(true)
+	assert.ElementsMatch(t, []string{
+		"users/:name: never matched, users/:id captures any word first",
+		"users/:id: captures any word, including the typos of its 3 static siblings",
+		"todo: no function and no subcommands",
+	}, shell.Lint())
+}
+
+func TestArgAliases(t *testing.T) {
+	shell := ishell.New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	var got []map[string]string
+	shell.AddCmd(&ishell.Cmd{
+		Name: "log",
+		Args: []ishell.Arg{
+			{Name: "--level", Aliases: []string{"-l"}, Pair: true, Optional: true, Default: "info", Choices: []string{"debug", "info"}},
+			{Name: "msg"},
+		},
+		Func: func(c *ishell.Context) { got = append(got, c.NamedArgs) },
+	})
+
+	assert.NoError(t, shell.Process("log", "-l", "debug", "hi"))
+	assert.NoError(t, shell.Process("log", "-l=debug", "hi"))
+	assert.NoError(t, shell.Process("log", "hi"))
+	assert.EqualError(t, shell.Process("log", "-l", "warn", "hi"), "invalid choice for --level: must be one of debug, info")
+	assert.Equal(t, []map[string]string{
+		{"--level": "debug", "msg": "hi"},
+		{"--level": "debug", "msg": "hi"},
+		{"--level": "info", "msg": "hi"},
+	}, got)
+
+	help, err := shell.HelpFor([]string{"log"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "--level|-l <value>")
+
+	assert.PanicsWithValue(t, "argument 'a' of 'x' has aliases but is not a pair", func() {
+		shell.AddCmd(&ishell.Cmd{Name: "x", Args: []ishell.Arg{{Name: "a", Aliases: []string{"b"}}}})
+	})
+	assert.PanicsWithValue(t, "argument name '-v' of 'y' is used twice", func() {
+		shell.AddCmd(&ishell.Cmd{Name: "y", Args: []ishell.Arg{
+			{Name: "--verbose", Aliases: []string{"-v"}, Pair: true},
+			{Name: "-v", Pair: true},
+		}})
+	})
+}
+
+func TestHelpForRelativePath(t *testing.T) {
+	var out bytes.Buffer
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("help ../status\nhelp /net/config/set\nhelp .. .. ..\nexit\n")),
+		Stdout: &out,
+	})
+	shell.SetOut(&out)
+	f := func(c *ishell.Context) {}
+	config := &ishell.Cmd{Name: "net/config", Help: "configure the network"}
+	shell.AddCmd(config)
+	shell.AddCmd(&ishell.Cmd{Name: "net/config/set", Help: "set a value", Func: f})
+	shell.AddCmd(&ishell.Cmd{Name: "net/status", Help: "show the status", Func: f})
+	shell.AddCmd(&ishell.Cmd{Name: "edit", Func: func(c *ishell.Context) { c.StartSubShell(config, "net/config> ") }})
+
+	help, err := shell.HelpFor([]string{"/net", "status"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "show the status")
+	help, err = shell.HelpFor([]string{"/"})
+	assert.NoError(t, err)
+	assert.Contains(t, help, "edit")
+	_, err = shell.HelpFor([]string{".."})
+	assert.EqualError(t, err, "cannot go above the top command")
+
+	// the built-in help of the sub-shell
+	assert.NoError(t, shell.Process("edit"))
+	assert.NotContains(t, out.String(), "incorrect input")
+	assert.Contains(t, out.String(), "show the status")
+	assert.Contains(t, out.String(), "set a value")
+	assert.Contains(t, out.String(), "cannot go above the top command")
+}
+
+func TestCommandTree(t *testing.T) {
+	shell := ishell.New()
+	shell.DeleteCmd("clear")
+	shell.DeleteCmd("exit")
+	f := func(c *ishell.Context) {}
+	shell.AddCmd(&ishell.Cmd{Name: "users", Help: "manage users"})
+	shell.AddCmd(&ishell.Cmd{Name: "users/list", Help: "list the users", Func: f})
+	shell.AddCmd(&ishell.Cmd{Name: "users/:id/show", Help: "show a user", Func: f})
+	shell.AddCmd(&ishell.Cmd{Name: "debug", Hidden: true})
+	shell.AddCmd(&ishell.Cmd{Name: "debug/dump", Func: f})
+
+	assert.Equal(t, `help      display help
+users     manage users
+  :id
+    show  show a user
+  list    list the users
+`, shell.CommandTree(0))
+	assert.Equal(t, `help   display help
+users  manage users
+`, shell.CommandTree(1))
+}
+
+func TestBatchInput(t *testing.T) {
+	if os.Getenv("ISHELL_BATCH_TEST") == "1" {
+		shell := ishell.New()
+		shell.AddCmd(&ishell.Cmd{Name: "greet", Func: func(c *ishell.Context) {
+			c.Println("hello " + c.Args[0])
+		}})
+		shell.AddCmd(&ishell.Cmd{Name: "fail", RunE: func(c *ishell.Context) error {
+			return errors.New("boom")
+		}})
+		shell.Run()
+		os.Exit(shell.ExitCode())
+	}
+
+	run := func(input string) (string, string, int) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestBatchInput$")
+		cmd.Env = append(os.Environ(), "ISHELL_BATCH_TEST=1")
+		cmd.Stdin = strings.NewReader(input)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return stdout.String(), stderr.String(), exitErr.ExitCode()
+		}
+		assert.NoError(t, err)
+		return stdout.String(), stderr.String(), 0
+	}
+
+	stdout, stderr, code := run("greet a\n\ngreet b\n")
+	assert.Equal(t, "hello a\nhello b\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Equal(t, 0, code)
+
+	stdout, stderr, code = run("greet a\nfail\ngreet b\n")
+	assert.Equal(t, "hello a\nhello b\n", stdout)
+	assert.Equal(t, "Error: boom\n", stderr)
+	assert.Equal(t, 1, code)
+
+	stdout, stderr, code = run("greet a\nnope\ngreet x\n")
+	assert.Equal(t, "hello a\nhello x\n", stdout)
+	assert.Contains(t, stderr, "Error:")
+	assert.Equal(t, 1, code)
+
+	stdout, _, code = run("greet a\nexit\ngreet b\n")
+	assert.Equal(t, "hello a\n", stdout)
+	assert.Equal(t, 0, code)
+}
+
+func TestCaseInsensitiveCompletionInsertsName(t *testing.T) {
+	shell := ishell.NewWithConfig(&readline.Config{
+		Stdin:  io.NopCloser(strings.NewReader("LIS\t\n")),
+		Stdout: io.Discard,
+	})
+	shell.SetCaseInsensitive(true)
+	var lines []string
+	shell.AddCmd(&ishell.Cmd{Name: "list", Func: func(c *ishell.Context) { lines = append(lines, c.Line) }})
+	shell.Run()
+	assert.Equal(t, []string{"list "}, lines)
+}
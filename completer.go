@@ -2,8 +2,10 @@ package ishell
 
 import (
 	"fmt"
+	"io"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/flynn-archive/go-shlex"
 )
@@ -16,19 +18,62 @@ type (
 	}
 	Suggestion struct {
 		Word     string
+		Kind     SuggestionKind
 		Param    bool   // param in command path or param for command
 		Optional bool   // optional argument
 		Help     string // help msg
+		Default  string // default value of optional argument
+
+		// suggestions are listed by group of Kind, then higher rank
+		// first, then by Word
+		rank int
 	}
+
+	// SuggestionKind tells what a Suggestion completes.
+	SuggestionKind uint8
+)
+
+const (
+	// SuggestCommand is a subcommand name or alias.
+	SuggestCommand SuggestionKind = iota
+	// SuggestArg is an argument of the command.
+	SuggestArg
+	// SuggestParam is a param in the command path.
+	SuggestParam
+	// SuggestValue is a value, from a custom completer or for a pair argument.
+	SuggestValue
 )
 
+// nextArgRank is the rank of the tip for the next required argument,
+// above the ones of the recalled values.
+const nextArgRank = maxArgHistory + 1
+
 type suggestionSorter []Suggestion
 
 func (s suggestionSorter) Len() int {
 	return len(s)
 }
 
+// group orders the kinds of suggestions: the commands first, then the
+// arguments and their values, then the params.
+func (k SuggestionKind) group() int {
+	switch k {
+	case SuggestCommand:
+		return 0
+	case SuggestArg, SuggestValue:
+		return 1
+	default:
+		return 2
+	}
+}
+
 func (s suggestionSorter) Less(i, j int) bool {
+	if gi, gj := s[i].Kind.group(), s[j].Kind.group(); gi != gj {
+		return gi < gj
+	}
+	if s[i].rank != s[j].rank {
+		return s[i].rank > s[j].rank
+	}
 	return s[i].Word < s[j].Word
 }
 
@@ -40,149 +85,531 @@ func (ic iCompleter) Do(line []rune, pos int) (newLine [][]rune, length int, off
 	if ic.disabled != nil && ic.disabled() {
 		return nil, 0, len(line)
 	}
-	var words []string
-	if w, err := shlex.Split(string(line)); err == nil {
-		words = w
-	} else {
-		// fall back
-		words = strings.Fields(string(line))
+	if ic.menuNext(line) {
+		return nil, 0, 0
 	}
-
-	var cWords []Suggestion
-
-	prefix := ""
-	if len(words) > 0 && pos > 0 && line[pos-1] != ' ' {
-		prefix = words[len(words)-1]
-		cWords = ic.getWords(prefix, words[:len(words)-1])
-	} else {
-		cWords = ic.getWords(prefix, words)
+	prefix, cWords := ic.suggest(line, pos)
+	start, quote := scanWord(line[:pos])
+	// escape inserts the end of a word the way it was started
+	escape := func(s string) []rune {
+		return []rune(escapeWord(s, quote, start == pos))
+	}
+	sink := ic.sink()
+	if sink != nil {
+		for _, w := range cWords {
+			fmt.Fprintf(sink, "%s\t%s\n", w.Word, w.Help)
+		}
 	}
 
 	var suggestions [][]rune
+	// fuzzy matches not starting with prefix, which replace it
+	var replacements []string
+	// all the matching words, for menu completion
+	var candidates []string
 
 	var tips []string
 
+	theme := ic.theme()
+	width := ic.width()
+
 	hasParam := false
 	for _, w := range cWords {
 		if w.Param {
 			hasParam = true
 		}
 
-		leftBracket := ""
-		righeBracket := ""
-		if w.Optional && !w.Param {
-			leftBracket = "["
-			righeBracket = "]"
-		}
-		leftAngle := ""
-		rightAngel := ""
+		tips = append(tips, tipLine(w, theme, width))
+
 		if w.Param {
-			leftAngle = "<"
-			rightAngel = ">"
+			continue
 		}
-
-		tip := fmt.Sprintf("%s%s%s%s%s",
-			leftBracket, leftAngle, w.Word, rightAngel, righeBracket)
-
-		tips = append(tips, fmt.Sprintf("%-15s %s", tip, w.Help))
-
-		if !w.Param && strings.HasPrefix(w.Word, prefix) {
+		if strings.HasPrefix(w.Word, prefix) {
 			suggestions = append(suggestions, []rune(strings.TrimPrefix(w.Word, prefix)))
+			candidates = append(candidates, w.Word)
+		} else if ic.hasMatch(w.Word, prefix) {
+			replacements = append(replacements, w.Word)
+			candidates = append(candidates, w.Word)
 		}
 	}
-	if len(suggestions) == 1 && prefix != "" && string(suggestions[0]) == "" {
-		suggestions = [][]rune{[]rune(" ")}
+	if ic.menuStart(line, pos, start, candidates) {
+		ic.printTips(tips)
+		return nil, 0, 0
+	}
+	if len(suggestions) == 0 && len(replacements) == 1 && !hasParam && pos == len(line) {
+		ic.replaceWord(line, start, replacements[0])
+		return nil, 0, 0
+	}
+	length = len(suggestions) + len(replacements)
+	switch {
+	case len(suggestions) == 1 && (!hasParam || string(suggestions[0]) == "" && prefix != ""):
+		// a single match, or a word completed already: finish the word
+		suggestions[0] = escape(string(suggestions[0]))
+		if quote != 0 {
+			suggestions[0] = append(suggestions[0], quote)
+		}
+		if pos == len(line) || line[pos] != ' ' {
+			suggestions[0] = append(suggestions[0], ' ')
+		}
 		hasParam = false
+	case len(suggestions) > 1 && len(replacements) == 0 && !hasParam:
+		// many matches: narrow the word to their longest common prefix
+		if common := commonPrefix(suggestions); len(common) > 0 {
+			ic.printTips(tips)
+			return [][]rune{escape(string(common))}, 1, pos - start
+		}
+	}
+	if len(suggestions) > 1 {
+		for i := range suggestions {
+			suggestions[i] = escape(string(suggestions[i]))
+		}
 	}
-
-	length = len(suggestions)
 	if hasParam {
 		length += 1
 	}
 
 	if length > 1 || hasParam {
-		for i, tip := range tips {
-			if i == 0 {
-				ic.shell.Println()
-			}
-			ic.shell.Println(tip)
+		ic.printTips(tips)
+	}
+
+	return suggestions, length, pos - start
+}
+
+// tipLine returns the completion tip of w, its help truncated to fit in
+// width if it is positive.
+func tipLine(w Suggestion, theme Theme, width int) string {
+	tip, n := tipText(w, theme)
+	// pad on the uncolored tip to keep the help aligned
+	pad := ""
+	if n < 15 {
+		pad = strings.Repeat(" ", 15-n)
+	}
+
+	help := w.Help
+	if width > 0 {
+		help = truncate(help, width-(n+len(pad))-1)
+	}
+	return tip + pad + " " + theme.help(help)
+}
+
+// tipText returns the word of w decorated for its tip, and its width
+// without colors. Optional words are in square brackets and params in
+// angle brackets, e.g. "[<name>]" for an optional param.
+func tipText(w Suggestion, theme Theme) (string, int) {
+	leftBracket := ""
+	rightBracket := ""
+	if w.Optional {
+		leftBracket = "["
+		rightBracket = "]"
+	}
+	leftAngle := ""
+	rightAngle := ""
+	if w.Param {
+		leftAngle = "<"
+		rightAngle = ">"
+	}
+
+	word := w.Word
+	if w.Default != "" {
+		word += "=" + w.Default
+	}
+
+	width := utf8.RuneCountInString(leftBracket + leftAngle + word + rightAngle + rightBracket)
+	return theme.optional(leftBracket) + theme.param(leftAngle) + theme.command(word) +
+		theme.param(rightAngle) + theme.optional(rightBracket), width
+}
+
+// printTips prints the completion tips below the line being edited,
+// up to the maximum number of items, unless the candidates go to a
+// completion sink.
+func (ic iCompleter) printTips(tips []string) {
+	if ic.sink() != nil {
+		return
+	}
+	more := 0
+	if n := ic.shell.maxCompletionItems; n > 0 && len(tips) > n {
+		tips, more = tips[:n], len(tips)-n
+	}
+	for i, tip := range tips {
+		if i == 0 {
+			ic.shell.Println()
 		}
+		ic.shell.Println(tip)
+	}
+	if more > 0 {
+		ic.shell.Println(fmt.Sprintf("...and %d more", more))
+	}
+}
+
+// commonPrefix returns the longest common prefix of words.
+func commonPrefix(words [][]rune) []rune {
+	common := words[0]
+	for _, w := range words[1:] {
+		n := 0
+		for n < len(common) && n < len(w) && common[n] == w[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return append([]rune(nil), common...)
+}
+
+// suggest returns the suggestions for line with the cursor at pos and
+// the prefix of the word being completed.
+func (ic iCompleter) suggest(line []rune, pos int) (string, []Suggestion) {
+	start, quote := scanWord(line[:pos])
+	text := string(line[:pos])
+	if quote != 0 {
+		// complete inside the quote
+		text += string(quote)
+	}
+	var words []string
+	if w, err := shlex.Split(text); err == nil {
+		words = w
+	} else {
+		// fall back
+		words = strings.Fields(text)
+	}
+
+	if len(words) > 0 && start < pos {
+		prefix := words[len(words)-1]
+		if len(words) == 1 && ic.shell != nil && ic.shell.slashPaths && strings.Contains(prefix, "/") {
+			return prefix, ic.slashWords(prefix)
+		}
+		return prefix, ic.getWords(prefix, ic.splitSlashPath(words[:len(words)-1]))
+	}
+	return "", ic.getWords("", ic.splitSlashPath(words))
+}
+
+// slashWords returns the suggestions for the slash separated command
+// path prefix, see Shell.SetSlashPaths. The subcommands are offered
+// joined to the path.
+func (ic iCompleter) slashWords(prefix string) (s []Suggestion) {
+	i := strings.LastIndex(prefix, "/")
+	for _, w := range ic.getWords(prefix[i+1:], splitSlashes(prefix[:i])) {
+		switch {
+		case w.Kind == SuggestCommand:
+			w.Word = prefix[:i+1] + w.Word
+		case w.Kind != SuggestParam:
+			// arguments follow the path after a space
+			continue
+		}
+		s = append(s, w)
+	}
+	return s
+}
+
+// splitSlashPath is Shell.splitSlashPath.
+func (ic iCompleter) splitSlashPath(words []string) []string {
+	if ic.shell == nil {
+		return words
+	}
+	return ic.shell.splitSlashPath(words)
+}
+
+// argSeparator returns the token after which the arguments are
+// positional.
+func (ic iCompleter) argSeparator() string {
+	if ic.shell == nil {
+		return defaultArgSeparator
+	}
+	return ic.shell.argSeparator
+}
+
+// sink returns the writer the candidates are written to, if any.
+func (ic iCompleter) sink() io.Writer {
+	if ic.shell == nil {
+		return nil
 	}
+	return ic.shell.completionSink
+}
+
+// theme returns the colors of the completion tips.
+func (ic iCompleter) theme() Theme {
+	if ic.shell == nil {
+		return Theme{}
+	}
+	return ic.shell.activeTheme()
+}
+
+// replaceWord replaces the word prefix at the end of line with word in
+// the readline buffer.
+func (ic iCompleter) replaceWord(line []rune, start int, word string) {
+	if ic.shell == nil {
+		return
+	}
+	head := string(line[:start])
+	ic.shell.reader.scanner.Operation.SetBuffer(head + escapeWord(word, 0, true) + " ")
+}
+
+// matchWord reports if word is a completion of prefix and the rank of
+// the match. Unless fuzzy completion is enabled word must start with
+// prefix. Case is ignored if the shell is case insensitive, the words
+// not starting with prefix exactly are then inserted in place of it.
+func (ic iCompleter) matchWord(word, prefix string) (int, bool) {
+	if ic.matcher().ignoreCase {
+		word, prefix = strings.ToLower(word), strings.ToLower(prefix)
+	}
+	if ic.shell != nil && ic.shell.fuzzyCompletion {
+		return fuzzyMatch(word, prefix)
+	}
+	return 0, strings.HasPrefix(word, prefix)
+}
+
+func (ic iCompleter) hasMatch(word, prefix string) bool {
+	_, ok := ic.matchWord(word, prefix)
+	return ok
+}
+
+// width returns the width of the terminal, or 0 if it is unknown.
+func (ic iCompleter) width() int {
+	if ic.shell == nil || !ic.shell.isTerminal() {
+		return 0
+	}
+	if f := ic.shell.reader.scanner.Config.FuncGetWidth; f != nil {
+		return max(f(), 0)
+	}
+	return 0
+}
+
+// truncate shortens s to width runes, ending it with an ellipsis if
+// it is cut.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
 
-	return suggestions, length, len(prefix)
+// matcher returns the options used to resolve the command being completed.
+func (ic iCompleter) matcher() matcher {
+	if ic.shell == nil {
+		return matcher{}
+	}
+	return ic.shell.match
+}
+
+// hideDisabled reports if the disabled commands are not suggested.
+func (ic iCompleter) hideDisabled() bool {
+	return ic.shell != nil && ic.shell.help.hideDisabled
+}
+
+// authorized reports if cmd should be suggested with regards to its
+// Authorize function. A panic of Authorize is recovered as for
+// execution and the command is not suggested.
+func (ic iCompleter) authorized(cmd *Cmd) bool {
+	if ic.shell == nil || !ic.shell.hideUnauthorized || cmd.Authorize == nil {
+		return true
+	}
+	return ic.shell.authorize(newContext(ic.shell, cmd, nil), cmd) == nil
 }
 
 func (ic iCompleter) getWords(prefix string, w []string) (s []Suggestion) {
 	ctx := &Context{}
-	cmd, args := ic.cmd.FindCmd(w, ctx)
+	cmd, args := ic.cmd.findCmd(w, ctx, ic.matcher())
 	if cmd == nil {
 		cmd, _ = ic.cmd, w
 	}
-	//if cmd.CompleterWithPrefix != nil {
-	//	return cmd.CompleterWithPrefix(prefix, args)
-	//}
-	//if cmd.Completer != nil {
-	//	return cmd.Completer(args)
-	//}
+	if cmd.NoCompletion || cmd.CompletionDisabled != nil && cmd.CompletionDisabled() {
+		return nil
+	}
+	if cmd.kind == CatchAllKind && cmd.CompleterWithPrefix == nil && cmd.Completer == nil {
+		// free-form until the end of line
+		return []Suggestion{{
+			Word:  cmd.Name,
+			Kind:  SuggestParam,
+			Param: true,
+			Help:  cmd.helpText(),
+		}}
+	}
+	if cmd.CompleterWithPrefix != nil {
+		return ic.customSuggestions(prefix, cmd.CompleterWithPrefix(prefix, args))
+	}
+	if cmd.Completer != nil {
+		return ic.customSuggestions(prefix, cmd.Completer(args))
+	}
 
-	for k, child := range cmd.staticChildren {
-		if !strings.HasPrefix(k, prefix) {
+	children := cmd.childrenWithPrefix(prefix, ic.matcher())
+	if ic.shell != nil && ic.shell.fuzzyCompletion {
+		children = cmd.Children()
+	}
+	for _, child := range children {
+		if child.Hidden || !ic.authorized(child) || child.disabled() && ic.hideDisabled() {
 			continue
 		}
 
-		s = append(s, Suggestion{
-			Word:     k,
-			Param:    false,
-			Optional: false,
-			Help:     child.helpText(),
-		})
-
-	}
+		if child.isParam() {
+			s = append(s, Suggestion{
+				Word:     child.Name,
+				Kind:     SuggestParam,
+				Param:    true,
+				Optional: false,
+				Help:     child.helpText(),
+			})
+			continue
+		}
 
-	if cmd.paramChild != nil {
-		s = append(s, Suggestion{
-			Word:     cmd.paramChild.Name,
-			Param:    true,
-			Optional: false,
-			Help:     cmd.paramChild.helpText(),
-		})
+		help := child.helpText()
+		if child.Deprecated != "" {
+			help += " (deprecated)"
+		}
+		if child.disabled() {
+			help += " (disabled)"
+		}
+		if rank, ok := ic.matchWord(child.Name, prefix); ok {
+			s = append(s, Suggestion{
+				Word:     child.Name,
+				Kind:     SuggestCommand,
+				Param:    false,
+				Optional: false,
+				Help:     help,
+				rank:     rank,
+			})
+			continue
+		}
+		// offer aliases only if the name does not match
+		for _, alias := range child.Aliases {
+			if rank, ok := ic.matchWord(alias, prefix); ok {
+				s = append(s, Suggestion{
+					Word:     alias,
+					Kind:     SuggestCommand,
+					Param:    false,
+					Optional: false,
+					Help:     "alias of " + child.Name,
+					rank:     rank,
+				})
+			}
+		}
 	}
 
 	defer func() {
-		sort.Sort(suggestionSorter(s))
+		sort.Stable(suggestionSorter(s))
 	}()
 
+	sep := ic.argSeparator()
+	literal := sep != "" && containsString(args, sep)
+
+	// supplied args, ignoring the values of pair args
 	argMap := make(map[string]struct{})
-	for _, arg := range args {
-		argMap[arg] = struct{}{}
+	for i := 0; i < len(args) && (sep == "" || args[i] != sep); i++ {
+		if arg, _ := cmd.inlinePair(args[i]); arg != nil {
+			argMap[arg.Name] = struct{}{}
+			continue
+		}
+		if arg := cmd.pairArg(args[i]); arg != nil {
+			argMap[arg.Name] = struct{}{}
+			i++
+			continue
+		}
+		argMap[args[i]] = struct{}{}
 	}
 
-	if len(args) > 0 {
-		last := args[len(args)-1]
-		for _, arg := range cmd.Args {
-			if arg.Name == last && arg.Pair {
-				s = append(s, Suggestion{
-					Word:     arg.Name,
-					Param:    true,
-					Optional: arg.Optional,
-					Help:     arg.Help,
-				})
-				return
-			}
+	// value of a partially typed name=value pair
+	if arg, value := cmd.inlinePair(prefix); !literal && arg != nil {
+		return ic.valueSuggestions(arg, prefix, value, prefix[:len(prefix)-len(value)])
+	}
+
+	if len(args) > 0 && !literal {
+		if arg := cmd.pairArg(args[len(args)-1]); arg != nil {
+			return ic.valueSuggestions(arg, prefix, prefix, "")
 		}
 	}
 
+	next := cmd.nextPositional(args, sep)
+	// tip for the argument being typed, or the next required one,
+	// unless its name is being typed
+	tip := next != nil && (prefix != "" || !next.Optional) && !next.hasSuggestions() && next.HistoryKey == "" &&
+		(prefix == "" || !ic.hasMatch(next.Name, prefix))
+	if next != nil && (next.hasSuggestions() || next.HistoryKey != "") {
+		s = append(s, ic.valueSuggestions(next, prefix, prefix, "")...)
+	} else if tip {
+		s = append(s, Suggestion{
+			Word:    next.Name,
+			Kind:    SuggestArg,
+			Param:   true,
+			Help:    next.Help,
+			Default: next.Default,
+			rank:    nextArgRank,
+		})
+	}
+
 	for _, arg := range cmd.Args {
-		if _, ok := argMap[arg.Name]; ok {
+		if _, ok := argMap[arg.Name]; ok && !arg.Variadic && !arg.Repeatable {
+			continue
+		}
+		// positional args with suggestions are offered by value only
+		if !arg.Pair && arg.hasSuggestions() {
+			continue
+		}
+		if tip && next.Name == arg.Name || literal && arg.Pair {
 			continue
 		}
 		s = append(s, Suggestion{
 			Word:     arg.Name,
+			Kind:     SuggestArg,
 			Param:    false,
 			Optional: arg.Optional,
 			Help:     arg.Help,
+			Default:  arg.Default,
 		})
 	}
 
 	return
 }
+
+// valueSuggestions returns the suggestions for the value of arg. The
+// values recorded for arg.HistoryKey, most recent first, and the ones
+// returned by arg.Suggest for value are offered prefixed with lead.
+func (ic iCompleter) valueSuggestions(arg *Arg, prefix, value, lead string) (s []Suggestion) {
+	seen := make(map[string]bool)
+	if arg.HistoryKey != "" && ic.shell != nil {
+		history := ic.shell.argHistory.get(arg.HistoryKey)
+		for i, w := range history {
+			seen[w] = true
+			if w = lead + w; ic.hasMatch(w, prefix) {
+				s = append(s, Suggestion{Word: w, Kind: SuggestValue, rank: len(history) - i})
+			}
+		}
+	}
+	if arg.hasSuggestions() {
+		candidates := arg.Choices
+		if arg.Suggest != nil {
+			candidates = append(append([]string(nil), candidates...), arg.Suggest(value)...)
+		}
+		var words []string
+		for _, w := range candidates {
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, lead+w)
+			}
+		}
+		s = append(s, ic.customSuggestions(prefix, words)...)
+	}
+	if arg.hasSuggestions() || arg.HistoryKey != "" {
+		return s
+	}
+	return []Suggestion{{
+		Word:     arg.Name,
+		Kind:     SuggestValue,
+		Param:    true,
+		Optional: arg.Optional,
+		Help:     arg.Help,
+		Default:  arg.Default,
+	}}
+}
+
+// customSuggestions converts the words returned by a custom completer
+// into suggestions, dropping the ones not matching prefix.
+func (ic iCompleter) customSuggestions(prefix string, words []string) (s []Suggestion) {
+	for _, w := range words {
+		rank, ok := ic.matchWord(w, prefix)
+		if !ok {
+			continue
+		}
+		s = append(s, Suggestion{Word: w, Kind: SuggestValue, rank: rank})
+	}
+	sort.Stable(suggestionSorter(s))
+	return
+}
@@ -0,0 +1,588 @@
+package ishell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func words(s []Suggestion) []string {
+	var w []string
+	for _, v := range s {
+		w = append(w, v.Word)
+	}
+	return w
+}
+
+func TestCustomCompleter(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "connect",
+		Completer: func(args []string) []string {
+			return []string{"beta", "alpha", "gamma"}
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"alpha", "beta", "gamma"}, words(ic.getWords("", []string{"connect"})))
+	assert.Equal(t, []string{"gamma"}, words(ic.getWords("g", []string{"connect"})))
+}
+
+func TestCustomCompleterWithPrefix(t *testing.T) {
+	root := &Cmd{}
+	var got string
+	root.AddCmd(&Cmd{
+		Name:      "connect",
+		Completer: func(args []string) []string { return []string{"ignored"} },
+		CompleterWithPrefix: func(prefix string, args []string) []string {
+			got = prefix
+			return []string{"host1", "host2", "other"}
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"host1", "host2"}, words(ic.getWords("ho", []string{"connect"})))
+	assert.Equal(t, "ho", got)
+}
+
+func TestHiddenCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{Name: "debug", Hidden: true})
+	root.AddCmd(&Cmd{Name: "deploy"})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"deploy"}, words(ic.getWords("de", nil)))
+	assert.Len(t, root.VisibleChildren(), 1)
+
+	cmd, _ := root.FindCmd([]string{"debug"}, nil)
+	assert.NotNil(t, cmd)
+}
+
+func TestPairArgsNotSuggestedTwice(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "run",
+		Args: []Arg{
+			{Name: "image", Pair: true},
+			{Name: "env", Pair: true, Repeatable: true},
+			{Name: "detach", Optional: true},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"detach", "env", "image"}, words(ic.getWords("", []string{"run"})))
+	assert.Equal(t, []string{"detach", "env"}, words(ic.getWords("", []string{"run", "image", "detach"})))
+	assert.Equal(t, []string{"detach", "env"}, words(ic.getWords("", []string{"run", "image", "alpine", "env", "A=1"})))
+	assert.Equal(t, []string{"image"}, words(ic.getWords("", []string{"run", "image"})))
+}
+
+func TestAliasCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{Name: "remove", Aliases: []string{"rm", "del"}})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"remove"}, words(ic.getWords("r", nil)))
+	assert.Equal(t, []string{"rm"}, words(ic.getWords("rm", nil)))
+	s := ic.getWords("d", nil)
+	assert.Equal(t, []Suggestion{{Word: "del", Help: "alias of remove"}}, s)
+}
+
+func TestSuggestionKind(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{Name: "user/:id"})
+	root.AddCmd(&Cmd{Name: "run", Args: []Arg{{Name: "image", Pair: true}}})
+	ic := iCompleter{cmd: root}
+
+	kinds := func(s []Suggestion) (k []SuggestionKind) {
+		for _, w := range s {
+			k = append(k, w.Kind)
+		}
+		return
+	}
+	assert.Equal(t, []SuggestionKind{SuggestCommand, SuggestCommand}, kinds(ic.getWords("", nil)))
+	assert.Equal(t, []SuggestionKind{SuggestParam}, kinds(ic.getWords("", []string{"user"})))
+	assert.Equal(t, []SuggestionKind{SuggestArg}, kinds(ic.getWords("", []string{"run"})))
+	assert.Equal(t, []SuggestionKind{SuggestValue}, kinds(ic.getWords("", []string{"run", "image"})))
+}
+
+func TestArgSuggest(t *testing.T) {
+	hosts := func(prefix string) []string {
+		return []string{"db1", "db2", "web1"}
+	}
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "connect",
+		Args: []Arg{
+			{Name: "host", Suggest: hosts},
+			{Name: "via", Pair: true, Optional: true, Suggest: hosts},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"db1", "db2", "via", "web1"}, words(ic.getWords("", []string{"connect"})))
+	assert.Equal(t, []string{"db1", "db2", "via"}, words(ic.getWords("db", []string{"connect"})))
+	assert.Equal(t, []string{"web1"}, words(ic.getWords("w", []string{"connect", "db1", "via"})))
+	assert.Equal(t, []string{"via"}, words(ic.getWords("", []string{"connect", "db1"})))
+}
+
+func TestInlinePairCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "serve",
+		Args: []Arg{
+			{Name: "port", Pair: true, Suggest: func(prefix string) []string { return []string{"80", "8080", "443"} }},
+			{Name: "host", Pair: true},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"port=80", "port=8080"}, words(ic.getWords("port=8", []string{"serve"})))
+	assert.Equal(t, []Suggestion{{Word: "host", Kind: SuggestValue, Param: true}}, ic.getWords("host=", []string{"serve"}))
+	assert.Equal(t, []string{"host"}, words(ic.getWords("", []string{"serve", "port=80"})))
+}
+
+func TestArgChoicesCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "log",
+		Args: []Arg{
+			{Name: "format", Choices: []string{"json", "text"}},
+			{Name: "level", Pair: true, Choices: []string{"debug", "info", "warn"}},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"json", "level", "text"}, words(ic.getWords("", []string{"log"})))
+	assert.Equal(t, []string{"debug"}, words(ic.getWords("d", []string{"log", "json", "level"})))
+	assert.Equal(t, []string{"level=info"}, words(ic.getWords("level=i", []string{"log", "json"})))
+}
+
+func TestArgSeparatorCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "run",
+		Args: []Arg{
+			{Name: "count", Pair: true, Suggest: func(string) []string { return []string{"1", "2"} }},
+			{Name: "target"},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"target", "count"}, words(ic.getWords("", []string{"run"})))
+	assert.Equal(t, []string{"target"}, words(ic.getWords("", []string{"run", "--"})))
+	assert.Equal(t, []string{"target"}, words(ic.getWords("", []string{"run", "--", "count"})))
+	assert.Equal(t, []string{"target"}, words(ic.getWords("count=", []string{"run", "--"})))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "a long…", truncate("a long help", 7))
+	assert.Equal(t, "", truncate("help", 0))
+}
+
+func TestFuzzyCompletion(t *testing.T) {
+	shell := New()
+	shell.AddCmd(&Cmd{Name: "git-commit"})
+	shell.AddCmd(&Cmd{Name: "gcloud"})
+	shell.AddCmd(&Cmd{Name: "grep-config"})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	assert.Equal(t, []string{"gcloud"}, words(ic.getWords("gc", nil)))
+	shell.SetFuzzyCompletion(true)
+	assert.Equal(t, []string{"gcloud", "git-commit", "grep-config"}, words(ic.getWords("gc", nil)))
+	shell.AddCmd(&Cmd{Name: "xci"})
+	assert.Equal(t, []string{"xci", "git-commit", "grep-config"}, words(ic.getWords("ci", nil)))
+}
+
+func TestCompletionSink(t *testing.T) {
+	shell := New()
+	var out, sink bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&Cmd{Name: "status", Help: "show status"})
+	shell.AddCmd(&Cmd{Name: "stop"})
+	shell.SetCompletionSink(&sink)
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	newLine, length, offset := ic.Do([]rune("st"), 2)
+	assert.Equal(t, [][]rune{[]rune("atus"), []rune("op")}, newLine)
+	assert.Equal(t, 2, length)
+	assert.Equal(t, 2, offset)
+	assert.Equal(t, "status\tshow status\nstop\t\n", sink.String())
+	assert.Empty(t, out.String())
+}
+
+func TestCommonPrefixCompletion(t *testing.T) {
+	shell := New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&Cmd{Name: "stat"})
+	shell.AddCmd(&Cmd{Name: "status"})
+	shell.AddCmd(&Cmd{Name: "stop"})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	// no candidate
+	newLine, length, _ := ic.Do([]rune("x"), 1)
+	assert.Empty(t, newLine)
+	assert.Equal(t, 0, length)
+
+	// a single candidate is completed with a trailing space
+	newLine, length, offset := ic.Do([]rune("sto"), 3)
+	assert.Equal(t, [][]rune{[]rune("p ")}, newLine)
+	assert.Equal(t, 1, length)
+	assert.Equal(t, 3, offset)
+	assert.Empty(t, out.String())
+
+	// many candidates are narrowed to their common prefix and listed
+	newLine, length, _ = ic.Do([]rune("sta"), 3)
+	assert.Equal(t, [][]rune{[]rune("t")}, newLine)
+	assert.Equal(t, 1, length)
+	assert.Contains(t, out.String(), "status")
+
+	// no common prefix left: only list
+	out.Reset()
+	newLine, length, _ = ic.Do([]rune("stat"), 4)
+	assert.Equal(t, [][]rune{[]rune(""), []rune("us")}, newLine)
+	assert.Equal(t, 2, length)
+	assert.Contains(t, out.String(), "stat")
+	newLine, _, _ = ic.Do([]rune("s"), 1)
+	assert.Equal(t, [][]rune{[]rune("t")}, newLine)
+
+	assert.Equal(t, "", string(commonPrefix([][]rune{[]rune("ab"), []rune("cd")})))
+	assert.Equal(t, "ab", string(commonPrefix([][]rune{[]rune("ab"), []rune("abc")})))
+	assert.Equal(t, "hé", string(commonPrefix([][]rune{[]rune("hé"), []rune("hé")})))
+}
+
+func TestMenuComplete(t *testing.T) {
+	shell := New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&Cmd{Name: "start"})
+	shell.AddCmd(&Cmd{Name: "status"})
+	shell.AddCmd(&Cmd{Name: "stop"})
+	shell.SetMenuComplete(true)
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	line := "st"
+	var inserted []string
+	for i := 0; i < 4; i++ {
+		newLine, _, _ := ic.Do([]rune(line), len(line))
+		assert.Empty(t, newLine)
+		line = shell.menu.line
+		inserted = append(inserted, line)
+	}
+	assert.Equal(t, []string{"start", "status", "stop", "start"}, inserted)
+
+	// an edited line starts over
+	newLine, _, _ := ic.Do([]rune("sto"), 3)
+	assert.Equal(t, [][]rune{[]rune("p ")}, newLine)
+}
+
+func TestQuotedCompletion(t *testing.T) {
+	shell := New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	shell.AddCmd(&Cmd{
+		Name: "open",
+		Completer: func(args []string) []string {
+			return []string{"my file.txt", "my dir", "it's"}
+		},
+	})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+	do := func(line string) string {
+		newLine, length, _ := ic.Do([]rune(line), len([]rune(line)))
+		if length != 1 || len(newLine) != 1 {
+			return fmt.Sprintf("%d candidates", length)
+		}
+		return string(newLine[0])
+	}
+
+	assert.Equal(t, `\ `, do("open my"))
+	assert.Equal(t, "2 candidates", do(`open my\ `))
+	assert.Equal(t, `ile.txt `, do(`open my\ f`))
+	assert.Equal(t, `ile.txt" `, do(`open "my f`))
+	assert.Equal(t, `\'s `, do("open it"))
+	assert.Equal(t, `'\''s' `, do("open 'it"))
+
+	for _, line := range []string{`open my\ file.txt `, `open "my f"ile.txt `, `open 'it'\''s' `} {
+		args, err := splitArgs(line)
+		assert.NoError(t, err)
+		assert.Len(t, args, 2)
+	}
+}
+
+func TestScanWord(t *testing.T) {
+	for _, test := range []struct {
+		line  string
+		start int
+		quote rune
+	}{
+		{"", 0, 0},
+		{"open ", 5, 0},
+		{"open my", 5, 0},
+		{`open my\ f`, 5, 0},
+		{`open "my f`, 5, '"'},
+		{`open 'a"b`, 5, '\''},
+		{`open "a\"b" c`, 12, 0},
+	} {
+		start, quote := scanWord([]rune(test.line))
+		assert.Equal(t, test.start, start, test.line)
+		assert.Equal(t, test.quote, quote, test.line)
+	}
+}
+
+func TestMaxCompletionItems(t *testing.T) {
+	shell := New()
+	var out bytes.Buffer
+	shell.SetOut(&out)
+	for i := 0; i < 5; i++ {
+		shell.AddCmd(&Cmd{Name: fmt.Sprintf("cmd%dx", i)})
+	}
+	shell.SetMaxCompletionItems(2)
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	newLine, length, _ := ic.Do([]rune("cm"), 2)
+	assert.Equal(t, [][]rune{[]rune("d")}, newLine)
+	assert.Equal(t, 1, length)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "cmd1x")
+	assert.Equal(t, "...and 3 more", lines[2])
+}
+
+func BenchmarkCompletion(b *testing.B) {
+	root := &Cmd{}
+	for i := 0; i < 2000; i++ {
+		name := fmt.Sprintf("cmd%04d", i)
+		root.AddCmd(&Cmd{Name: name, Aliases: []string{"a" + name}})
+	}
+	ic := iCompleter{cmd: root}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ic.getWords("cmd19", nil)
+	}
+}
+
+func TestNextRequiredArgTip(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "cp",
+		Args: []Arg{
+			{Name: "src", Help: "source"},
+			{Name: "dst"},
+			{Name: "force", Pair: true, Optional: true},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	s := ic.getWords("", []string{"cp"})
+	assert.Equal(t, []string{"src", "dst", "force"}, words(s))
+	assert.True(t, s[0].Param)
+	assert.Equal(t, "source", s[0].Help)
+
+	s = ic.getWords("", []string{"cp", "a"})
+	assert.Equal(t, "dst", s[0].Word)
+	assert.True(t, s[0].Param)
+}
+
+func TestNoCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{Name: "comment", NoCompletion: true, Args: []Arg{{Name: "text"}}})
+	root.AddCmd(&Cmd{Name: "comment/sub"})
+	ic := iCompleter{cmd: root}
+	assert.Empty(t, ic.getWords("", []string{"comment"}))
+	assert.Equal(t, []string{"comment"}, words(ic.getWords("com", nil)))
+}
+
+func TestCompletionDisabled(t *testing.T) {
+	root := &Cmd{}
+	disabled := true
+	root.AddCmd(&Cmd{Name: "login", CompletionDisabled: func() bool { return disabled }, Args: []Arg{{Name: "user"}}})
+	ic := iCompleter{cmd: root}
+	assert.Empty(t, ic.getWords("", []string{"login"}))
+	disabled = false
+	assert.Equal(t, []string{"user"}, words(ic.getWords("", []string{"login"})))
+}
+
+func TestArgHelpWhileTyping(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "cp",
+		Args: []Arg{
+			{Name: "src", Help: "source"},
+			{Name: "dst", Optional: true, Help: "destination"},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	s := ic.getWords("/tm", []string{"cp", "a"})
+	assert.Equal(t, Suggestion{Word: "dst", Kind: SuggestArg, Param: true, Help: "destination", rank: nextArgRank}, s[0])
+
+	s = ic.getWords("d", []string{"cp", "a"})
+	assert.Equal(t, []string{"dst", "src"}, words(s))
+	assert.False(t, s[0].Param)
+}
+
+func TestSuggestionRanking(t *testing.T) {
+	root := &Cmd{}
+	deploy := &Cmd{
+		Name: "deploy",
+		Args: []Arg{
+			{Name: "force", Optional: true},
+			{Name: "dry-run", Optional: true},
+		},
+	}
+	root.AddCmd(deploy)
+	deploy.AddCmd(&Cmd{Name: "stage"})
+	deploy.AddCmd(&Cmd{Name: ":env"})
+	deploy.AddCmd(&Cmd{Name: "prod"})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"prod", "stage", "dry-run", "force", "env"}, words(ic.getWords("", []string{"deploy"})))
+
+	// fuzzy matches are listed after the prefix ones of their group
+	ic.shell = &Shell{fuzzyCompletion: true}
+	deploy.AddCmd(&Cmd{Name: "reset"})
+	deploy.AddCmd(&Cmd{Name: "restart"})
+	assert.Equal(t, []string{"stage", "reset", "restart", "force"}, words(ic.getWords("s", []string{"deploy"}))[:4])
+}
+
+func TestSlashPathCompletion(t *testing.T) {
+	shell := New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.SetSlashPaths(true)
+	shell.AddCmd(&Cmd{Name: "users/list"})
+	shell.AddCmd(&Cmd{Name: "users/:id/delete", Args: []Arg{{Name: "force", Optional: true}}})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	prefix, s := ic.suggest([]rune("users/"), 6)
+	assert.Equal(t, "users/", prefix)
+	assert.Equal(t, []string{"users/list", "id"}, words(s))
+
+	_, s = ic.suggest([]rune("users/42/d"), 10)
+	assert.Equal(t, []string{"users/42/delete"}, words(s))
+
+	newLine, length, _ := ic.Do([]rune("users/42/d"), 10)
+	assert.Equal(t, 1, length)
+	assert.Equal(t, "elete ", string(newLine[0]))
+
+	// arguments only follow a space
+	_, s = ic.suggest([]rune("users/42/delete/"), 16)
+	assert.Empty(t, s)
+	_, s = ic.suggest([]rune("users/42/delete "), 16)
+	assert.Equal(t, []string{"force"}, words(s))
+}
+
+func TestDisabledCompletion(t *testing.T) {
+	shell := New()
+	enabled := false
+	shell.AddCmd(&Cmd{Name: "deploy", Help: "deploy the app", Enabled: func() bool { return enabled }})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	s := ic.getWords("de", nil)
+	assert.Equal(t, []string{"deploy"}, words(s))
+	assert.Equal(t, "deploy the app (disabled)", s[0].Help)
+
+	shell.SetHideDisabled(true)
+	assert.Empty(t, ic.getWords("de", nil))
+	enabled = true
+	assert.Equal(t, "deploy the app", ic.getWords("de", nil)[0].Help)
+}
+
+func TestTipText(t *testing.T) {
+	for _, tc := range []struct {
+		optional, param bool
+		want            string
+	}{
+		{false, false, "name"},
+		{true, false, "[name]"},
+		{false, true, "<name>"},
+		{true, true, "[<name>]"},
+	} {
+		tip, width := tipText(Suggestion{Word: "name", Optional: tc.optional, Param: tc.param}, Theme{})
+		assert.Equal(t, tc.want, tip)
+		assert.Equal(t, len(tc.want), width)
+	}
+}
+
+func TestHelpCompletion(t *testing.T) {
+	shell := New()
+	shell.AddCmd(&Cmd{Name: "users/list"})
+	shell.AddCmd(&Cmd{Name: "users/lock"})
+	shell.AddCmd(&Cmd{Name: "users/:id/show"})
+	shell.AddCmd(&Cmd{Name: "secret", Hidden: true})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	_, s := ic.suggest([]rune("help "), 5)
+	assert.Equal(t, []string{"clear", "exit", "help", "users"}, words(s))
+	_, s = ic.suggest([]rune("help users l"), 12)
+	assert.Equal(t, []string{"list", "lock"}, words(s))
+	_, s = ic.suggest([]rune("help users 42 "), 14)
+	assert.Equal(t, []string{"show"}, words(s))
+	_, s = ic.suggest([]rune("help nope "), 10)
+	assert.Empty(t, s)
+}
+
+func TestArgAliasCompletion(t *testing.T) {
+	root := &Cmd{}
+	root.AddCmd(&Cmd{
+		Name: "log",
+		Args: []Arg{
+			{Name: "--level", Aliases: []string{"-l"}, Pair: true, Optional: true, Choices: []string{"debug", "info"}},
+			{Name: "--quiet", Pair: true, Optional: true},
+		},
+	})
+	ic := iCompleter{cmd: root}
+	assert.Equal(t, []string{"debug", "info"}, words(ic.getWords("", []string{"log", "-l"})))
+	// the argument is supplied once through its alias
+	assert.Equal(t, []string{"--quiet"}, words(ic.getWords("", []string{"log", "-l", "info"})))
+}
+
+func TestCaseInsensitiveCompletion(t *testing.T) {
+	shell := New()
+	shell.SetOut(&bytes.Buffer{})
+	shell.AddCmd(&Cmd{Name: "list"})
+	shell.AddCmd(&Cmd{Name: "Lint", Aliases: []string{"check"}})
+	shell.AddCmd(&Cmd{Name: "status"})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+
+	assert.Empty(t, ic.getWords("LI", nil))
+	shell.SetCaseInsensitive(true)
+	assert.Equal(t, []string{"Lint", "list"}, words(ic.getWords("LI", nil)))
+	assert.Equal(t, []string{"check"}, words(ic.getWords("CH", nil)))
+
+	// a word not matching the case is replaced, not completed
+	newLine, length, _ := ic.Do([]rune("LIS"), 3)
+	assert.Nil(t, newLine)
+	assert.Equal(t, 0, length)
+}
+
+func TestTipLineTruncation(t *testing.T) {
+	color := func(a ...interface{}) string { return "\x1b[31m" + fmt.Sprint(a...) + "\x1b[0m" }
+	w := Suggestion{Word: "deploy", Help: "deploy the application"}
+	assert.Equal(t, "deploy          deploy the…", tipLine(w, Theme{}, 27))
+	assert.Equal(t, "\x1b[31mdeploy\x1b[0m          deploy the…", tipLine(w, Theme{Command: color}, 27))
+}
+
+func TestTheme(t *testing.T) {
+	defer func(f func(int) bool) { isTerminalFd = f }(isTerminalFd)
+	terminal := true
+	isTerminalFd = func(int) bool { return terminal }
+	red := func(a ...interface{}) string { return "\x1b[31m" + fmt.Sprint(a...) + "\x1b[0m" }
+
+	shell := New()
+	shell.SetOut(os.Stdout)
+	shell.AddCmd(&Cmd{Name: "deploy", Help: "deploy the application", Func: func(c *Context) {}})
+	shell.SetTheme(Theme{Command: red, Help: red})
+	ic := iCompleter{shell: shell, cmd: shell.rootCmd}
+	tip := Suggestion{Word: "deploy", Help: "deploy the application"}
+
+	t.Setenv("NO_COLOR", "")
+	assert.Contains(t, shell.HelpText(), "\x1b[31mdeploy\x1b[0m")
+	assert.Contains(t, shell.HelpText(), "\x1b[31mdeploy the application\x1b[0m")
+	assert.Equal(t, "\x1b[31mdeploy\x1b[0m          \x1b[31mdeploy the application\x1b[0m", tipLine(tip, ic.theme(), 80))
+
+	t.Setenv("NO_COLOR", "1")
+	assert.NotContains(t, shell.HelpText(), "\x1b[")
+	assert.Equal(t, Theme{}, ic.theme())
+
+	t.Setenv("NO_COLOR", "")
+	terminal = false
+	assert.NotContains(t, shell.HelpText(), "\x1b[")
+	assert.Equal(t, "deploy          deploy the application", tipLine(tip, ic.theme(), 80))
+
+	terminal = true
+	shell.SetOut(&bytes.Buffer{})
+	assert.NotContains(t, shell.HelpText(), "\x1b[")
+}
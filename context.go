@@ -1,11 +1,22 @@
 package ishell
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
 type (
 	// Context is an ishell context. It embeds ishell.Actions.
 	Context struct {
 		contextValues
 		progressBar ProgressBar
 		err         error
+		unhandled   bool
+		writer      io.Writer
+		shell       *Shell
 
 		// Args is command arguments.
 		Args []string
@@ -13,11 +24,27 @@ type (
 		// RawArgs is unprocessed command arguments.
 		RawArgs []string
 
+		// Line is the input line as entered, with its quoting and
+		// whitespace preserved.
+		Line string
+
 		Params []Param
 
+		// NamedArgs is command arguments resolved against Cmd.Args,
+		// keyed by argument name.
+		NamedArgs map[string]string
+
+		argLists map[string][]string
+
 		// Cmd is the currently executing command. This is empty for NotFound and Interrupt.
 		Cmd Cmd
 
+		// Ctx is cancelled when the command returns, when its
+		// Timeout elapses or on Ctrl-C in an interactive shell. Long
+		// running commands should honor it and return early, a second
+		// Ctrl-C kills the program.
+		Ctx context.Context
+
 		Actions
 	}
 
@@ -33,11 +60,179 @@ func (c *Context) Err(err error) {
 	c.err = err
 }
 
+// Write prints p to the shell output. It makes Context an io.Writer,
+// for use with fmt.Fprintf, json.NewEncoder and the like.
+func (c *Context) Write(p []byte) (int, error) {
+	c.Print(string(p))
+	return len(p), nil
+}
+
+// Flush flushes the shell output if its writer is buffered, such as a
+// bufio.Writer set with SetOut.
+func (c *Context) Flush() error {
+	if f, ok := c.writer.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// ReadLineWithPrompt prints prompt and reads a line of input with the
+// shell's readline instance. While RunScript runs, the line is the next
+// one of the script and io.EOF is returned at its end.
+func (c *Context) ReadLineWithPrompt(prompt string) (string, error) {
+	if line, ok, err := c.shell.scriptLine(); ok {
+		return line, err
+	}
+	c.Print(prompt)
+	return c.ReadLineErr()
+}
+
+// ReadPasswordWithPrompt is ReadLineWithPrompt without echoing the
+// characters typed.
+func (c *Context) ReadPasswordWithPrompt(prompt string) (string, error) {
+	if line, ok, err := c.shell.scriptLine(); ok {
+		return line, err
+	}
+	c.Print(prompt)
+	return c.ReadPasswordErr()
+}
+
+// Confirm reads an answer to prompt with ReadLineWithPrompt and reports
+// if it is y or yes, in any case. A read error is a no.
+func (c *Context) Confirm(prompt string) bool {
+	answer, err := c.ReadLineWithPrompt(prompt)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// HelpFor returns the help of the command at the path args, see
+// Shell.HelpFor.
+func (c *Context) HelpFor(args []string) (string, error) {
+	return c.shell.HelpFor(args)
+}
+
+// PrintPaged prints text, through the pager if the shell is interactive
+// and text does not fit in the terminal.
+func (c *Context) PrintPaged(text string) {
+	c.shell.PrintPaged(text)
+}
+
+// Unhandled tells the NotFound handler did not handle the input. The
+// default unknown command message is then printed.
+func (c *Context) Unhandled() {
+	c.unhandled = true
+}
+
+// Done returns a channel closed when Ctx is cancelled.
+func (c *Context) Done() <-chan struct{} {
+	return c.Ctx.Done()
+}
+
 // ProgressBar returns the progress bar for the current shell context.
 func (c *Context) ProgressBar() ProgressBar {
 	return c.progressBar
 }
 
+// Param returns the value of the param named key and whether it was
+// matched. If several params share key, the last one wins.
+func (c *Context) Param(key string) (string, bool) {
+	for i := len(c.Params) - 1; i >= 0; i-- {
+		if c.Params[i].Key == key {
+			return c.Params[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// ParamInt returns the value of the param named key as an int.
+func (c *Context) ParamInt(key string) (int, error) {
+	v, ok := c.Param(key)
+	if !ok {
+		return 0, fmt.Errorf("missing param: %s", key)
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid int value %q for param %s", v, key)
+	}
+	return i, nil
+}
+
+// ParamMap returns the params keyed by name. If several params share
+// a key, the last one wins.
+func (c *Context) ParamMap() map[string]string {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	return params
+}
+
+// ArgValue returns the value of the argument named name and whether
+// it was supplied.
+func (c *Context) ArgValue(name string) (string, bool) {
+	v, ok := c.NamedArgs[name]
+	return v, ok
+}
+
+// ArgList returns the values collected by the variadic or repeatable
+// argument named name.
+func (c *Context) ArgList(name string) []string {
+	return c.argLists[name]
+}
+
+func (c *Context) argValue(name string) (string, error) {
+	v, ok := c.NamedArgs[name]
+	if !ok {
+		return "", fmt.Errorf("missing argument: %s", name)
+	}
+	return v, nil
+}
+
+// ArgInt returns the value of the argument named name as an int.
+func (c *Context) ArgInt(name string) (int, error) {
+	v, err := c.argValue(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid int value %q for argument %s", v, name)
+	}
+	return i, nil
+}
+
+// ArgBool returns the value of the argument named name as a bool.
+func (c *Context) ArgBool(name string) (bool, error) {
+	v, err := c.argValue(name)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid bool value %q for argument %s", v, name)
+	}
+	return b, nil
+}
+
+// ArgFloat returns the value of the argument named name as a float64.
+func (c *Context) ArgFloat(name string) (float64, error) {
+	v, err := c.argValue(name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float value %q for argument %s", v, name)
+	}
+	return f, nil
+}
+
 // contextValues is the map for values in the context.
 type contextValues map[string]interface{}
 
@@ -45,7 +45,7 @@ func main() {
 	// handle "greet".
 	shell.AddCmd(&ishell.Cmd{
 		Name:    "greet",
-		Aliases: []string{"hello", "welcome"},
+		Aliases: []string{"hi", "welcome"},
 		Help:    "greet user",
 		Func: func(c *ishell.Context) {
 			name := "Stranger"
@@ -0,0 +1,78 @@
+package ishell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainResolution returns a trace of how line resolves to a command,
+// without running it: the alias expansion, the node matched by each
+// word and how, the captured params and the remaining args. e.g.
+//
+//	"users" -> users (static)
+//	"42" -> users/:id (param id=42)
+//	"show" -> users/:id/show (static)
+//	args: ["-v"]
+func (s *Shell) ExplainResolution(line string) string {
+	var b strings.Builder
+	words, err := splitArgs(line)
+	if err != nil {
+		return "error: " + err.Error() + "\n"
+	}
+	words = s.splitSlashPath(words)
+	expanded, err := s.expandAliases(append([]string(nil), words...))
+	if err != nil {
+		return "error: " + err.Error() + "\n"
+	}
+	if strings.Join(expanded, " ") != strings.Join(words, " ") {
+		fmt.Fprintf(&b, "alias: %q -> %q\n", words, expanded)
+	}
+	words = expanded
+	if s.ignoreCase {
+		for i := range words {
+			words[i] = strings.ToLower(words[i])
+		}
+	}
+
+	path, args := s.rootCmd.findCmdPath(words, nil, s.match)
+
+	defer s.rootCmd.rlockTree()()
+	if len(path) == 0 {
+		b.WriteString("no command matched\n")
+	}
+	for i, cmd := range path {
+		word := words[i]
+		switch cmd.kind {
+		case CatchAllKind:
+			value := strings.Join(words[i:], spliter)
+			fmt.Fprintf(&b, "%q -> %s (catchall %s=%s)\n", value, cmd.fullName(), cmd.Name, value)
+		case ParamKind:
+			fmt.Fprintf(&b, "%q -> %s (param %s=%s)\n", word, cmd.fullName(), cmd.Name, word)
+		default:
+			fmt.Fprintf(&b, "%q -> %s (%s)\n", word, cmd.fullName(), s.match.how(cmd, word))
+		}
+	}
+	if len(path) > 0 && !path[len(path)-1].runnable() {
+		b.WriteString("no function, the help is shown\n")
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(&b, "args: %q\n", args)
+	}
+	return b.String()
+}
+
+// how tells how word matched the static command cmd.
+func (m matcher) how(cmd *Cmd, word string) string {
+	if m.equal(word, cmd.Name) {
+		return "static"
+	}
+	for _, alias := range cmd.Aliases {
+		if m.equal(word, alias) {
+			return "alias " + alias
+		}
+	}
+	if m.prefixMatch && m.hasPrefix(cmd.Name, word) {
+		return "prefix"
+	}
+	return "normalized"
+}
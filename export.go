@@ -0,0 +1,155 @@
+package ishell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type (
+	cmdJSON struct {
+		Name     string    `json:"name"`
+		Kind     string    `json:"kind"`
+		Aliases  []string  `json:"aliases,omitempty"`
+		Help     string    `json:"help,omitempty"`
+		LongHelp string    `json:"longHelp,omitempty"`
+		Args     []argJSON `json:"args,omitempty"`
+		Children []*Cmd    `json:"children,omitempty"`
+	}
+
+	argJSON struct {
+		Name     string `json:"name"`
+		Pair     bool   `json:"pair,omitempty"`
+		Optional bool   `json:"optional,omitempty"`
+		Help     string `json:"help,omitempty"`
+	}
+)
+
+func (k kind) String() string {
+	switch k {
+	case ParamKind:
+		return "param"
+	case CatchAllKind:
+		return "catchall"
+	}
+	return "static"
+}
+
+// MarshalJSON returns the JSON encoding of the command and its subcommands.
+// Param commands are named with their ':' prefix.
+func (c *Cmd) MarshalJSON() ([]byte, error) {
+	unlock := c.rlockTree()
+	v := cmdJSON{
+		Name:     c.displayName(),
+		Kind:     c.kind.String(),
+		Aliases:  c.Aliases,
+		Help:     c.Help,
+		LongHelp: c.LongHelp,
+		Children: c.children(),
+	}
+	unlock()
+
+	for _, arg := range c.Args {
+		v.Args = append(v.Args, argJSON{
+			Name:     arg.Name,
+			Pair:     arg.Pair,
+			Optional: arg.Optional,
+			Help:     arg.Help,
+		})
+	}
+	return json.Marshal(v)
+}
+
+// DumpCommands returns the indented JSON encoding of the command tree
+// of the shell.
+func (s *Shell) DumpCommands() ([]byte, error) {
+	return json.MarshalIndent(s.rootCmd, "", "  ")
+}
+
+// bashEntry is the completion words offered after a command path.
+type bashEntry struct {
+	pattern string
+	depth   int
+	glob    bool
+	words   []string
+}
+
+// bashEntries returns the completion entries of cmd and its subcommands.
+func bashEntries(cmd *Cmd, pattern string, depth int, glob bool) []bashEntry {
+	var entries []bashEntry
+	var words []string
+	for _, child := range cmd.visibleChildren() {
+		if child.isParam() {
+			// no static completion for params, only for what follows them
+			entries = append(entries, bashEntries(child, pattern+`/*`, depth+1, true)...)
+			continue
+		}
+		words = append(words, child.Name)
+		words = append(words, child.Aliases...)
+		for _, name := range append([]string{child.Name}, child.Aliases...) {
+			entries = append(entries, bashEntries(child, pattern+`/"`+name+`"`, depth+1, glob)...)
+		}
+	}
+	for _, arg := range cmd.Args {
+		if !arg.Pair {
+			words = append(words, arg.Name)
+		}
+	}
+	// commands without words are kept so that their path is not
+	// matched by the glob of a param sibling.
+	return append(entries, bashEntry{pattern: pattern, depth: depth, glob: glob, words: words})
+}
+
+// GenBashCompletion writes a bash completion script for the command tree
+// of the shell to w. The script completes the program named as os.Args[0].
+func (s *Shell) GenBashCompletion(w io.Writer) error {
+	prog := filepath.Base(os.Args[0])
+	fn := "_" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, prog) + "_completion"
+
+	unlock := s.rootCmd.rlockTree()
+	entries := bashEntries(s.rootCmd, "", 0, false)
+	unlock()
+	// deeper and static paths first as globs match any number of words.
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].depth != entries[j].depth {
+			return entries[i].depth > entries[j].depth
+		}
+		return !entries[i].glob && entries[j].glob
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintln(&b, `    local cur path i`)
+	fmt.Fprintln(&b, `    cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(&b, `    path=""`)
+	fmt.Fprintln(&b, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(&b, `        path="${path}/${COMP_WORDS[i]}"`)
+	fmt.Fprintln(&b, `    done`)
+	fmt.Fprintln(&b, `    case "${path}" in`)
+	for _, e := range entries {
+		pattern := e.pattern
+		if pattern == "" {
+			pattern = `""`
+		}
+		fmt.Fprintf(&b, "    %s)\n", pattern)
+		fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(e.words, " "))
+		fmt.Fprintln(&b, `        ;;`)
+	}
+	fmt.Fprintln(&b, `    esac`)
+	fmt.Fprintln(&b, `}`)
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, prog)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
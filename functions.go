@@ -4,12 +4,26 @@ import (
 	"os"
 )
 
-func exitFunc(c *Context) {
+const (
+	defaultExitName = "exit"
+	defaultHelpName = "help"
+)
+
+func (s *Shell) exitFunc(c *Context) {
+	if s.exitConfirm != nil && !s.exitConfirm() {
+		return
+	}
 	c.Stop()
 }
 
 func helpFunc(c *Context) {
-	c.Println(c.HelpText())
+	help, err := c.HelpFor(c.Args)
+	if help != "" {
+		c.PrintPaged(help + "\n")
+	}
+	if err != nil {
+		c.Err(err)
+	}
 }
 
 func clearFunc(c *Context) {
@@ -20,24 +34,57 @@ func clearFunc(c *Context) {
 }
 
 func addDefaultFuncs(s *Shell) {
-	s.AddCmd(&Cmd{
-		Name: "exit",
-		Help: "exit the program",
-		Func: exitFunc,
-	})
-	s.AddCmd(&Cmd{
-		Name: "help",
-		Help: "display help",
-		Func: helpFunc,
-	})
-	s.AddCmd(&Cmd{
+	s.addExitCmd(defaultExitName)
+	s.addHelpCmd(defaultHelpName)
+	s.clearCmd = &Cmd{
 		Name: "clear",
 		Help: "clear the screen",
 		Func: clearFunc,
-	})
+	}
+	s.AddCmd(s.clearCmd)
 	s.Interrupt(interruptFunc)
 }
 
+func (s *Shell) addExitCmd(name string) {
+	s.exitCmd = &Cmd{
+		Name: name,
+		Help: "exit the program",
+		Func: s.exitFunc,
+	}
+	s.AddCmd(s.exitCmd)
+}
+
+func (s *Shell) addHelpCmd(name string) {
+	s.helpCmd = &Cmd{
+		Name: name,
+		Help: "display help",
+		Func: helpFunc,
+
+		CompleterWithPrefix: s.helpCompleter,
+	}
+	s.AddCmd(s.helpCmd)
+}
+
+// helpCompleter completes the path of the command whose help is asked
+// for with the subcommands of the command at args.
+func (s *Shell) helpCompleter(prefix string, args []string) []string {
+	cmd := s.rootCmd
+	if len(args) > 0 {
+		var rest []string
+		cmd, rest = s.rootCmd.findCmd(args, &Context{}, s.match)
+		if cmd == nil || len(rest) > 0 {
+			return nil
+		}
+	}
+	var names []string
+	for _, child := range cmd.VisibleChildren() {
+		if !child.isParam() {
+			names = append(names, child.Name)
+		}
+	}
+	return names
+}
+
 func interruptFunc(c *Context, count int, line string) {
 	if count >= 2 {
 		c.Println("Interrupted")
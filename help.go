@@ -0,0 +1,290 @@
+package ishell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// helpConfig holds the options used to render help texts.
+// The zero value renders the default help.
+type helpConfig struct {
+	verbose       bool
+	categoryOrder []string
+	theme         Theme
+	hideDisabled  bool
+}
+
+// HelpText returns the computed help of the command and its subcommands.
+func (c *Cmd) HelpText() string {
+	return c.helpTextWith(helpConfig{})
+}
+
+func (c *Cmd) helpTextWith(conf helpConfig) string {
+	return c.cachedHelp(conf)
+}
+
+// renderHelp renders the help text of c.
+func (c *Cmd) renderHelp(conf helpConfig) string {
+	// LongHelpFunc may walk the tree, call it before locking
+	help := c.helpText()
+
+	// Enabled and the theme are user funcs, call them once unlocked
+	unlock := c.rlockTree()
+	usage := c.usage()
+	hasSubcommand := c.hasSubcommand()
+	children := c.visibleChildren()
+	unlock()
+
+	var b bytes.Buffer
+	p := func(s ...interface{}) {
+		fmt.Fprintln(&b)
+		if len(s) > 0 {
+			fmt.Fprintln(&b, s...)
+		}
+	}
+	// a command only grouping subcommands needs no help of its own
+	container := help == "" && c.Name != "" && !c.runnable() && hasSubcommand
+	if help != "" {
+		p(help)
+	} else if c.Name != "" && !container {
+		p(c.Name, "has no help")
+	}
+	if len(usage) > 0 {
+		p("Usage:", usage[0])
+		for _, u := range usage[1:] {
+			fmt.Fprintln(&b, "Usage:", u)
+		}
+	}
+	if len(c.Args) > 0 {
+		p("Arguments:")
+		writeArgs(&b, c.Args, conf.theme)
+	}
+	if len(c.Examples) > 0 {
+		p("Examples:")
+		for _, example := range c.Examples {
+			fmt.Fprintln(&b, "  "+example)
+		}
+	}
+	if hasSubcommand {
+		groups, categories := groupCategories(helpChildren(children, conf), conf.categoryOrder)
+		if len(groups[""]) > 0 || len(categories) == 0 {
+			categories = append([]string{""}, categories...)
+		}
+		for i, category := range categories {
+			heading := category + ":"
+			if category == "" {
+				heading = "Commands:"
+				if container {
+					heading = "Available subcommands:"
+				}
+			}
+			if i == 0 {
+				p(heading)
+			} else {
+				fmt.Fprintln(&b, heading)
+			}
+			writeCommands(&b, groups[category], conf.theme)
+			p()
+		}
+	}
+	return b.String()
+}
+
+// usage returns the usage lines of c. It is empty for the root command.
+func (c *Cmd) usage() []string {
+	path := c.path()
+	if path == "" {
+		return nil
+	}
+	var lines []string
+	if c.runnable() || len(c.Args) > 0 || !c.hasSubcommand() {
+		line := path
+		for _, arg := range c.Args {
+			line += " " + arg.signature()
+		}
+		lines = append(lines, line)
+	}
+	if c.hasSubcommand() {
+		lines = append(lines, path+" <subcommand>")
+	}
+	return lines
+}
+
+// path returns the space separated names of the commands leading
+// to c, param commands rendered as <name>.
+func (c *Cmd) path() string {
+	var names []string
+	for cmd := c; cmd != nil && cmd.Name != ""; cmd = cmd.parent {
+		name := cmd.Name
+		switch cmd.kind {
+		case ParamKind:
+			name = "<" + name + ">"
+		case CatchAllKind:
+			name = "<" + name + ">..."
+		}
+		names = append([]string{name}, names...)
+	}
+	return strings.Join(names, " ")
+}
+
+// signature returns the usage form of the argument.
+func (a Arg) signature() string {
+	var s string
+	switch {
+	case a.Pair:
+		s = strings.Join(append([]string{a.Name}, a.Aliases...), "|") + " <value>"
+	case a.Variadic && a.Optional:
+		s = a.Name + "..."
+	case a.Variadic:
+		s = "<" + a.Name + ">..."
+	case a.Optional:
+		s = a.Name
+	default:
+		s = "<" + a.Name + ">"
+	}
+	if a.Optional {
+		s = "[" + s + "]"
+	}
+	return s
+}
+
+// helpChildren returns the visible subcommands children listed in the
+// help of their parent.
+func helpChildren(children []*Cmd, conf helpConfig) []*Cmd {
+	var cmds []*Cmd
+	for _, child := range children {
+		if child.Deprecated != "" && !conf.verbose || conf.hideDisabled && child.disabled() {
+			continue
+		}
+		cmds = append(cmds, child)
+	}
+	return cmds
+}
+
+// groupCategories groups cmds by category. It returns the groups and the
+// non empty categories, the ones in order first and the others sorted.
+func groupCategories(cmds []*Cmd, order []string) (map[string][]*Cmd, []string) {
+	groups := make(map[string][]*Cmd)
+	for _, cmd := range cmds {
+		groups[cmd.Category] = append(groups[cmd.Category], cmd)
+	}
+
+	var categories []string
+	seen := map[string]bool{"": true}
+	for _, category := range order {
+		if _, ok := groups[category]; ok && !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	var rest []string
+	for category := range groups {
+		if !seen[category] {
+			rest = append(rest, category)
+		}
+	}
+	sort.Strings(rest)
+	return groups, append(categories, rest...)
+}
+
+// writeCommands writes the aligned listing of cmds to w.
+func writeCommands(w io.Writer, cmds []*Cmd, theme Theme) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range cmds {
+		help := cmd.Help
+		if cmd.Deprecated != "" {
+			help += " (deprecated)"
+		}
+		if cmd.disabled() {
+			help += " (disabled)"
+		}
+		fmt.Fprintf(tw, "\t%s\t\t\t%s\n", theme.command(cmd.Name), theme.help(help))
+	}
+	tw.Flush()
+}
+
+// writeArgs writes the aligned signatures and help of args.
+func writeArgs(w io.Writer, args []Arg, theme Theme) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, arg := range args {
+		fmt.Fprintf(tw, "\t%s\t\t\t%s\n", theme.command(arg.signature()), theme.help(arg.Help))
+	}
+	tw.Flush()
+}
+
+// helpText returns the help of the command.
+func (c *Cmd) helpText() string {
+	if c.LongHelpFunc != nil {
+		return c.LongHelpFunc(c)
+	}
+	if c.LongHelp != "" {
+		return c.LongHelp
+	}
+	return c.Help
+}
+
+// HelpFor returns the help of the command at the path args, e.g.
+// "users delete". Params are matched by value or by their ':' or '*'
+// prefixed name. The path is relative to the commands of the shell,
+// or of the running sub-shell: ".." goes up to the parent command and
+// a leading "/" starts from the top of the command tree, e.g.
+// "../users/list". If only the beginning of the path is known, the help
+// of the deepest matched command is returned with an error.
+func (s *Shell) HelpFor(args []string) (string, error) {
+	if len(args) == 0 {
+		return s.rootCmd.helpTextWith(s.helpConfig()), nil
+	}
+
+	// the normalizer is a user func, findChildCmd locks the tree itself
+	locked := func(f func()) {
+		defer s.rootCmd.rlockTree()()
+		f()
+	}
+	cmd, i := s.rootCmd, 0
+	moved := false
+	if first := args[0]; strings.HasPrefix(first, "/") || strings.HasPrefix(first, "..") {
+		if first[0] == '/' {
+			locked(func() { cmd = cmd.root() })
+			moved = true
+		}
+		args = append(splitSlashes(first), args[1:]...)
+	}
+	for ; i < len(args) && cmd.kind != CatchAllKind; i++ {
+		if args[i] == ".." {
+			var parent *Cmd
+			locked(func() { parent = cmd.parent })
+			if parent == nil {
+				return "", errors.New("cannot go above the top command")
+			}
+			cmd, moved = parent, true
+			continue
+		}
+		var next *Cmd
+		if isParamName(args[i]) {
+			locked(func() { next = cmd.paramChild(args[i][1:]) })
+		}
+		if next == nil {
+			next = findChildCmd(cmd, args[i], s.match)
+		}
+		if next == nil {
+			break
+		}
+		cmd, moved = next, true
+	}
+	var path string
+	locked(func() { path = cmd.path() })
+
+	if i < len(args) && (!moved || path == "") {
+		return "", fmt.Errorf("unknown command %q", args[i])
+	}
+	help := cmd.helpTextWith(s.helpConfig())
+	if i < len(args) && cmd.kind != CatchAllKind {
+		return help, fmt.Errorf("unknown subcommand %q of %q", args[i], path)
+	}
+	return help, nil
+}
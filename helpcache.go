@@ -0,0 +1,118 @@
+package ishell
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// helpCache holds the rendered help texts of the commands. The texts of
+// a generation are discarded once the command tree changes, a text is
+// rendered again once the help fields it was rendered from change.
+var helpCache = struct {
+	sync.Mutex
+	gen     uint64
+	entries map[*Cmd]helpEntry
+}{}
+
+type helpEntry struct {
+	verbose       bool
+	categoryOrder []string
+	fingerprint   string
+	text          string
+}
+
+// invalidateHelp discards the cached help texts.
+func invalidateHelp() {
+	helpCache.Lock()
+	defer helpCache.Unlock()
+	helpCache.gen++
+	helpCache.entries = nil
+}
+
+// InvalidateHelpCache discards the cached help texts of all commands.
+// Changes of the help fields of the commands, e.g. Help or Hidden, are
+// detected without it.
+func (c *Cmd) InvalidateHelpCache() {
+	invalidateHelp()
+}
+
+// cachedHelp returns the help text of c, rendering it if it is not
+// cached. Help rendered with colors, generated by LongHelpFunc or
+// listing subcommands with an Enabled function is not cached.
+func (c *Cmd) cachedHelp(conf helpConfig) string {
+	if c.LongHelpFunc != nil || !conf.theme.isZero() || c.hasEnabledChild() {
+		return c.renderHelp(conf)
+	}
+
+	fingerprint := c.helpFingerprint()
+	helpCache.Lock()
+	gen := helpCache.gen
+	entry, ok := helpCache.entries[c]
+	helpCache.Unlock()
+	if ok && entry.verbose == conf.verbose && entry.fingerprint == fingerprint &&
+		equalStrings(entry.categoryOrder, conf.categoryOrder) {
+		return entry.text
+	}
+
+	text := c.renderHelp(conf)
+
+	helpCache.Lock()
+	defer helpCache.Unlock()
+	if helpCache.gen == gen {
+		if helpCache.entries == nil {
+			helpCache.entries = make(map[*Cmd]helpEntry)
+		}
+		helpCache.entries[c] = helpEntry{
+			verbose:       conf.verbose,
+			categoryOrder: append([]string(nil), conf.categoryOrder...),
+			fingerprint:   fingerprint,
+			text:          text,
+		}
+	}
+	return text
+}
+
+// helpFingerprint returns the fields of c and of its subcommands its
+// help is rendered from.
+func (c *Cmd) helpFingerprint() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%q %q %t %q\n", c.Help, c.LongHelp, c.runnable(), c.Examples)
+	for _, arg := range c.Args {
+		fmt.Fprintf(&b, "%q %q %t %t %t %q\n", arg.Name, arg.Aliases, arg.Pair, arg.Variadic, arg.Optional, arg.Help)
+	}
+	defer c.rlockTree()()
+	for _, child := range c.children() {
+		fmt.Fprintf(&b, "%q %q %t %q %q\n", child.Name, child.Help, child.Hidden, child.Deprecated, child.Category)
+	}
+	return b.String()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasEnabledChild reports if a subcommand of c has an Enabled function,
+// whose result can change between two renderings.
+func (c *Cmd) hasEnabledChild() bool {
+	defer c.rlockTree()()
+	for _, child := range c.staticChildren {
+		if child.Enabled != nil {
+			return true
+		}
+	}
+	for _, child := range c.paramChildren {
+		if child.Enabled != nil {
+			return true
+		}
+	}
+	return false
+}
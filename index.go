@@ -0,0 +1,107 @@
+package ishell
+
+import (
+	"sort"
+	"strings"
+)
+
+// indexChild adds the names and aliases of the static subcommand child of
+// c to the lookup indexes of c.
+func (c *Cmd) indexChild(child *Cmd) {
+	c.sortedNames = insertSorted(c.sortedNames, child.Name)
+	for _, alias := range child.Aliases {
+		if c.aliasIndex == nil {
+			c.aliasIndex = make(map[string]*Cmd)
+		}
+		if _, ok := c.aliasIndex[alias]; !ok {
+			c.aliasIndex[alias] = child
+			c.sortedAliases = insertSorted(c.sortedAliases, alias)
+		}
+	}
+}
+
+// unindexChild removes the static subcommand child of c from the lookup
+// indexes of c.
+func (c *Cmd) unindexChild(child *Cmd) {
+	c.sortedNames = removeSorted(c.sortedNames, child.Name)
+	for _, alias := range child.Aliases {
+		if c.aliasIndex[alias] == child {
+			delete(c.aliasIndex, alias)
+			c.sortedAliases = removeSorted(c.sortedAliases, alias)
+		}
+	}
+}
+
+// childrenWithPrefix returns the subcommands of c whose name or one of
+// the aliases starts with prefix as compared by m, and all the param
+// subcommands. They are ordered like Children.
+func (c *Cmd) childrenWithPrefix(prefix string, m matcher) []*Cmd {
+	defer c.rlockTree()()
+	if prefix == "" {
+		return c.children()
+	}
+
+	matching := func(sorted []string) []string {
+		if !m.ignoreCase {
+			return prefixRange(sorted, prefix)
+		}
+		var names []string
+		for _, name := range sorted {
+			if m.hasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	var cmds []*Cmd
+	for _, name := range matching(c.sortedNames) {
+		cmds = append(cmds, c.staticChildren[name])
+	}
+	for _, alias := range matching(c.sortedAliases) {
+		if cmd := c.aliasIndex[alias]; !m.hasPrefix(cmd.Name, prefix) && !containsCmd(cmds, cmd) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	cmds = append(cmds, c.paramChildren...)
+	c.sortCmds(cmds)
+	return cmds
+}
+
+func containsCmd(cmds []*Cmd, cmd *Cmd) bool {
+	for _, c := range cmds {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixRange returns the names of the sorted slice names starting with
+// prefix.
+func prefixRange(names []string, prefix string) []string {
+	i := sort.SearchStrings(names, prefix)
+	j := i
+	for j < len(names) && strings.HasPrefix(names[j], prefix) {
+		j++
+	}
+	return names[i:j]
+}
+
+func insertSorted(names []string, name string) []string {
+	i := sort.SearchStrings(names, name)
+	if i < len(names) && names[i] == name {
+		return names
+	}
+	names = append(names, "")
+	copy(names[i+1:], names[i:])
+	names[i] = name
+	return names
+}
+
+func removeSorted(names []string, name string) []string {
+	i := sort.SearchStrings(names, name)
+	if i == len(names) || names[i] != name {
+		return names
+	}
+	return append(names[:i], names[i+1:]...)
+}
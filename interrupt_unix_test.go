@@ -0,0 +1,62 @@
+//go:build darwin || dragonfly || freebsd || (linux && !appengine) || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux,!appengine netbsd openbsd solaris
+
+package ishell_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/liqianrain/ishell"
+	"github.com/liqianrain/readline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextInterrupt(t *testing.T) {
+	in, input := io.Pipe()
+	shell := ishell.NewWithConfig(&readline.Config{Stdin: in, Stdout: io.Discard})
+	shell.SetOut(io.Discard)
+	started := make(chan struct{})
+	errs := make(chan error, 1)
+	after := make(chan struct{})
+	shell.AddCmd(&ishell.Cmd{
+		Name: "wait",
+		Func: func(c *ishell.Context) {
+			close(started)
+			select {
+			case <-c.Done():
+				errs <- c.Ctx.Err()
+			case <-time.After(5 * time.Second):
+				errs <- nil
+			}
+		},
+	})
+	shell.AddCmd(&ishell.Cmd{Name: "after", Func: func(c *ishell.Context) { close(after) }})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		shell.Run()
+	}()
+
+	_, err := io.WriteString(input, "wait\n")
+	assert.NoError(t, err)
+	<-started
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	assert.Equal(t, context.Canceled, <-errs)
+
+	// the shell keeps running the next commands
+	_, err = io.WriteString(input, "after\n")
+	assert.NoError(t, err)
+	select {
+	case <-after:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shell stopped after the interrupt")
+	}
+	assert.True(t, shell.Active())
+	input.Close()
+	<-stopped
+}
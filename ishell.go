@@ -4,11 +4,13 @@ package ishell
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"runtime"
@@ -40,25 +42,60 @@ var (
 
 // Shell is an interactive cli shell.
 type Shell struct {
-	rootCmd           *Cmd
-	generic           func(*Context)
-	interrupt         func(*Context, int, string)
-	interruptCount    int
-	eof               func(*Context)
-	reader            *shellReader
-	writer            io.Writer
-	active            bool
-	activeMutex       sync.RWMutex
-	ignoreCase        bool
-	customCompleter   bool
-	multiChoiceActive bool
-	haltChan          chan struct{}
-	historyFile       string
-	autoHelp          bool
-	rawArgs           []string
-	progressBar       ProgressBar
-	pager             string
-	pagerArgs         []string
+	rootCmd            *Cmd
+	generic            func(*Context)
+	before             func(*Context)
+	after              func(*Context)
+	stats              cmdStats
+	argHistory         argHistory
+	interrupt          func(*Context, int, string)
+	interruptCount     int
+	eof                func(*Context)
+	panicHandler       func(*Context, interface{})
+	errorHandler       func(error)
+	reader             *shellReader
+	writer             io.Writer
+	active             bool
+	activeMutex        sync.RWMutex
+	ignoreCase         bool
+	hideUnauthorized   bool
+	match              matcher
+	help               helpConfig
+	theme              Theme
+	suggestDistance    int
+	fuzzyCompletion    bool
+	slashPaths         bool
+	keyBindings        map[rune]rune
+	osStdin            bool
+	batch              bool
+	exitCode           int
+	completionSink     io.Writer
+	menuComplete       bool
+	dispatched         func(c *Context, cmd *Cmd)
+	argSeparator       string
+	emptyLineHandler   func()
+	eofHandler         func() bool
+	maxCompletionItems int
+	menu               menuState
+	customCompleter    bool
+	multiChoiceActive  bool
+	haltChan           chan struct{}
+	historyFile        string
+	exitCmd            *Cmd
+	exitConfirm        func() bool
+	script             *scriptReader
+	helpCmd            *Cmd
+	clearCmd           *Cmd
+	builtins           []*Cmd
+	autoHelp           bool
+	helpOnEmptyFunc    bool
+	haltOnError        bool
+	aliases            map[string]string
+	rawArgs            []string
+	rawLine            string
+	progressBar        ProgressBar
+	pager              string
+	pagerArgs          []string
 	contextValues
 	Actions
 }
@@ -70,13 +107,16 @@ func New() *Shell {
 
 // NewWithConfig creates a new shell with custom readline config.
 func NewWithConfig(conf *readline.Config) *Shell {
+	osStdin := conf.Stdin == nil
 	rl, err := readline.NewEx(conf)
 	if err != nil {
 		log.Println("Shell or operating system not supported.")
 		log.Fatal(err)
 	}
 
-	return NewWithReadline(rl)
+	shell := NewWithReadline(rl)
+	shell.osStdin = osStdin
+	return shell
 }
 
 // NewWithReadline creates a new shell with a custom readline instance.
@@ -91,8 +131,12 @@ func NewWithReadline(rl *readline.Instance) *Shell {
 			buf:         &bytes.Buffer{},
 			completer:   readline.NewPrefixCompleter(),
 		},
-		writer:   rl.Config.Stdout,
-		autoHelp: true,
+		writer:          rl.Config.Stdout,
+		autoHelp:        true,
+		helpOnEmptyFunc: true,
+		haltOnError:     true,
+		argSeparator:    defaultArgSeparator,
+		suggestDistance: defaultSuggestDistance,
 	}
 	shell.Actions = &shellActionsImpl{Shell: shell}
 	shell.progressBar = newProgressBar(shell)
@@ -107,8 +151,17 @@ func (s *Shell) Start() {
 }
 
 // Run starts the shell and waits for it to stop.
+//
+// If the shell reads the standard input and it is not a terminal, e.g.
+// "echo cmd | app", the lines are run one by one until EOF, without
+// prompt nor completion. Failing lines do not stop the next ones, their
+// errors are printed to the standard error, see ExitCode.
 func (s *Shell) Run() {
 	s.prepareRun()
+	if s.isBatch() {
+		s.runBatch()
+		return
+	}
 	s.run()
 }
 
@@ -182,17 +235,26 @@ shell:
 			continue shell
 		}
 
+		if err == io.EOF && s.eofHandler != nil {
+			if s.eofHandler() {
+				break
+			}
+			continue
+		}
 		if err == io.EOF {
 			if s.eof == nil {
+				if s.exitConfirm != nil && !s.exitConfirm() {
+					continue
+				}
 				fmt.Println("EOF")
 				break
 			}
 			if err := handleEOF(s); err != nil {
-				s.Println("Error:", err)
+				s.printError(err)
 				continue
 			}
 		} else if err != nil && err != readline.ErrInterrupt {
-			s.Println("Error:", err)
+			s.printError(err)
 			continue
 		}
 
@@ -206,17 +268,36 @@ shell:
 			// normal flow
 			if len(line) == 0 {
 				// no input line
+				if s.emptyLineHandler != nil {
+					s.emptyLineHandler()
+				}
 				continue
 			}
 
 			err = handleInput(s, line)
 		}
 		if err != nil {
-			s.Println("Error:", err)
+			s.printError(err)
 		}
 	}
 }
 
+// printError reports err to the error handler, or prints it.
+func (s *Shell) printError(err error) {
+	if s.batch {
+		s.exitCode = 1
+	}
+	if s.errorHandler != nil {
+		s.errorHandler(err)
+		return
+	}
+	if s.batch {
+		fmt.Fprintln(s.reader.scanner.Config.Stderr, "Error:", err)
+		return
+	}
+	s.Println("Error:", err)
+}
+
 // Active tells if the shell is active. i.e. Start is previously called.
 func (s *Shell) Active() bool {
 	s.activeMutex.RLock()
@@ -225,11 +306,155 @@ func (s *Shell) Active() bool {
 }
 
 // Process runs shell using args in a non-interactive mode.
+// There is no input line, Context.Line is empty.
 func (s *Shell) Process(args ...string) error {
+	s.rawArgs = nil
+	s.rawLine = ""
+	return handleInput(s, args)
+}
+
+// Eval runs line as if it was entered in the shell and returns what
+// the command printed. It is not safe to call Eval while the shell
+// prints from another goroutine.
+func (s *Shell) Eval(line string) (string, error) {
+	var out bytes.Buffer
+	writer := s.writer
+	s.writer = &out
+	defer func() { s.writer = writer }()
+
+	err := s.evalLine(line)
+	return out.String(), err
+}
+
+// Result is the outcome of a line run with EvalResult.
+type Result struct {
+	// Command is the full name of the command run, e.g.
+	// "users/:id/show". It is empty if no command ran.
+	Command string
+	// Args, NamedArgs and Params are the arguments and params of the
+	// command, as in its Context.
+	Args      []string
+	NamedArgs map[string]string
+	Params    []Param
+	// Output is what the command printed.
+	Output string
+	// Err is the error set by the command.
+	Err error
+}
+
+// EvalResult is Eval returning the command run and its arguments along
+// with the output. The returned error is the one Eval returns.
+func (s *Shell) EvalResult(line string) (Result, error) {
+	var r Result
+	dispatched := s.dispatched
+	s.dispatched = func(c *Context, cmd *Cmd) {
+		unlock := cmd.rlockTree()
+		r.Command = cmd.fullName()
+		unlock()
+		r.Args, r.NamedArgs, r.Params, r.Err = c.Args, c.NamedArgs, c.Params, c.err
+	}
+	defer func() { s.dispatched = dispatched }()
+
+	out, err := s.Eval(line)
+	r.Output = out
+	return r, err
+}
+
+// RunScript runs the commands read from r, one per line. Blank lines
+// and lines starting with '#' are skipped, and a trailing backslash
+// joins a line with the next one. See SetHaltOnError for how errors
+// are handled. Commands reading input with Context.ReadLineWithPrompt,
+// ReadPasswordWithPrompt or Confirm read the next lines of the script.
+func (s *Shell) RunScript(r io.Reader) error {
+	script := &scriptReader{Scanner: bufio.NewScanner(r)}
+	prev := s.script
+	s.script = script
+	defer func() { s.script = prev }()
+	var line string
+	start := 0
+	for script.next() {
+		text := script.Text()
+		if line == "" {
+			start = script.n
+			if trimmed := strings.TrimSpace(text); trimmed == "" || trimmed[0] == '#' {
+				continue
+			}
+		}
+		if strings.HasSuffix(text, "\\") {
+			line += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		if err := s.runScriptLine(line+text, start); err != nil {
+			return err
+		}
+		line = ""
+	}
+	if line != "" {
+		if err := s.runScriptLine(line, start); err != nil {
+			return err
+		}
+	}
+	return script.Err()
+}
+
+// scriptReader reads the lines of a script run by RunScript, counting
+// them.
+type scriptReader struct {
+	*bufio.Scanner
+	n int
+}
+
+func (r *scriptReader) next() bool {
+	if !r.Scan() {
+		return false
+	}
+	r.n++
+	return true
+}
+
+// scriptLine returns the next line of the script being run, for the
+// commands reading input. ok is false if no script is running.
+func (s *Shell) scriptLine() (line string, ok bool, err error) {
+	if s.script == nil {
+		return "", false, nil
+	}
+	if !s.script.next() {
+		if err = s.script.Err(); err == nil {
+			err = io.EOF
+		}
+		return "", true, err
+	}
+	return s.script.Text(), true, nil
+}
+
+func (s *Shell) runScriptLine(line string, n int) error {
+	err := s.evalLine(line)
+	if err == nil {
+		return nil
+	}
+	if s.haltOnError {
+		return fmt.Errorf("line %d: %w", n, err)
+	}
+	s.printError(err)
+	return nil
+}
+
+// evalLine tokenizes line and runs it as shell input.
+func (s *Shell) evalLine(line string) error {
+	args, err := splitArgs(line)
+	if err != nil || len(args) == 0 {
+		return err
+	}
+	s.rawArgs = strings.Fields(line)
+	s.rawLine = line
 	return handleInput(s, args)
 }
 
 func handleInput(s *Shell, line []string) error {
+	line, err := s.expandAliases(s.splitSlashPath(line))
+	if err != nil {
+		return err
+	}
 	handled, err := s.handleCommand(line)
 	if handled || err != nil {
 		return err
@@ -237,11 +462,40 @@ func handleInput(s *Shell, line []string) error {
 
 	// Generic handler
 	if s.generic == nil {
-		return errNoHandler
+		if len(line) == 0 {
+			return errNoHandler
+		}
+		return s.notFoundErr(line[0])
 	}
 	c := newContext(s, nil, line)
+	if c.RawArgs == nil {
+		c.RawArgs = line
+	}
 	s.generic(c)
-	return c.err
+	if c.err != nil || !c.unhandled || len(line) == 0 {
+		return c.err
+	}
+	return s.notFoundErr(line[0])
+}
+
+// notFoundErr returns the error for the unknown command name.
+func (s *Shell) notFoundErr(name string) error {
+	unlock := s.rootCmd.rlockTree()
+	var names []string
+	if s.match.prefixMatch {
+		names = prefixChildren(s.rootCmd, name, s.match)
+	}
+	children := s.rootCmd.children()
+	unlock()
+
+	if len(names) > 1 {
+		return fmt.Errorf("ambiguous command %q, could be %s", name, strings.Join(names, ", "))
+	}
+	// Enabled is a user func, closestChild calls it unlocked
+	if closest := closestChild(children, name, s.suggestDistance, s.match); closest != "" {
+		return fmt.Errorf("unknown command %q, did you mean %q?", name, closest)
+	}
+	return errNoHandler
 }
 
 func handleInterrupt(s *Shell, line []string) error {
@@ -267,22 +521,188 @@ func (s *Shell) handleCommand(str []string) (bool, error) {
 		}
 	}
 	ctx := &Context{}
-	cmd, args := s.rootCmd.FindCmd(str, ctx)
-	if cmd == nil {
-		return false, nil
+	path, args := s.rootCmd.findCmdPath(str, ctx, s.match)
+	if len(path) == 0 {
+		builtin := s.builtinCmd(str)
+		if builtin == nil {
+			return false, nil
+		}
+		path, args = []*Cmd{builtin}, str[1:]
+	}
+	cmd := path[len(path)-1]
+	if !cmd.runnable() {
+		unlock := cmd.rlockTree()
+		group := cmd.hasSubcommand()
+		unlock()
+		// trigger help if func is not registered, unless disabled for
+		// commands grouping subcommands
+		if !group || s.helpOnEmptyFunc {
+			s.Println(cmd.helpTextWith(s.helpConfig()))
+		}
+		return true, nil
 	}
-	// trigger help if func is not registered or auto help is true
-	if cmd.Func == nil || (s.autoHelp && len(args) == 1 && args[0] == "help") {
-		s.Println(cmd.HelpText())
+	// trigger help if auto help is true
+	if s.autoHelp && len(args) == 1 && args[0] == s.rootCmd.helpName() {
+		s.Println(cmd.helpTextWith(s.helpConfig()))
 		return true, nil
 	}
 	c := newContext(s, cmd, args)
 	c.Params = ctx.Params
+	if cmd.disabled() {
+		return true, fmt.Errorf("command %q is disabled", cmd.Name)
+	}
+	if cmd.Authorize != nil {
+		if err := s.authorize(c, cmd); err != nil {
+			return true, err
+		}
+	}
+	resolved, err := cmd.resolveArgs(args, s.argSeparator)
+	if err != nil {
+		return true, err
+	}
+	c.NamedArgs = resolved.values
+	c.argLists = resolved.lists
+	s.recordArgs(cmd, resolved)
 
-	cmd.Func(c)
+	if cmd.Deprecated != "" {
+		s.Println(fmt.Sprintf("Warning: command %q is deprecated, %s", cmd.Name, cmd.Deprecated))
+	}
+
+	var cancel context.CancelFunc
+	if cmd.Timeout > 0 {
+		c.Ctx, cancel = context.WithTimeout(c.Ctx, cmd.Timeout)
+	} else {
+		c.Ctx, cancel = context.WithCancel(c.Ctx)
+	}
+	defer cancel()
+
+	if s.Active() {
+		defer cancelOnInterrupt(cancel)()
+	}
+	s.execute(c, path)
+	if s.dispatched != nil {
+		s.dispatched(c, cmd)
+	}
+	if c.Ctx.Err() == context.DeadlineExceeded {
+		s.Println(fmt.Sprintf("Warning: command %q timed out after %s", cmd.Name, cmd.Timeout))
+	}
 	return true, c.err
 }
 
+// cancelOnInterrupt calls cancel on the first interrupt signal received
+// until stop is called. Later signals get their default behaviour, so a
+// command ignoring the cancellation can still be killed.
+func cancelOnInterrupt(cancel context.CancelFunc) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+			signal.Stop(sig)
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
+
+// authorize runs the Authorize function of cmd. A panic denies the
+// command.
+func (s *Shell) authorize(c *Context, cmd *Cmd) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err = s.recoverPanic(c, r); err == nil {
+				err = fmt.Errorf("authorization of %q panicked", cmd.Name)
+			}
+		}
+	}()
+	return cmd.Authorize(c)
+}
+
+// execute runs the command of c surrounded by the before and after hooks
+// of the shell and of the commands in path, recovering from their panics.
+func (s *Shell) execute(c *Context, path []*Cmd) {
+	defer s.recoverErr(c)
+	s.stats.Lock()
+	enabled := s.stats.enabled
+	s.stats.Unlock()
+	if enabled {
+		start := time.Now()
+		defer func() {
+			s.stats.record(path[len(path)-1].fullName(), time.Since(start))
+		}()
+	}
+	if s.after != nil {
+		defer s.after(c)
+	}
+	if s.before != nil {
+		s.before(c)
+	}
+	for _, cmd := range path {
+		if cmd.After != nil {
+			defer cmd.After(c)
+		}
+		if cmd.Before != nil {
+			cmd.Before(c)
+		}
+	}
+	s.runFunc(c, path[len(path)-1])
+}
+
+// maxPanicFrames is the number of stack frames printed for a panicking
+// command.
+const maxPanicFrames = 8
+
+// recoverPanic handles the value r recovered from a panic of the command
+// of c, of its hooks or of Authorize. It is passed to the panic handler
+// if set, otherwise it is printed with the stack of the panic and
+// returned as an error.
+func (s *Shell) recoverPanic(c *Context, r interface{}) error {
+	if s.panicHandler != nil {
+		s.panicHandler(c, r)
+		return nil
+	}
+	s.Printf("panic: %v\n", r)
+	// skip runtime.Callers, recoverPanic, the deferred recoverErr and gopanic
+	pcs := make([]uintptr, maxPanicFrames)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(4, pcs)])
+	for {
+		frame, more := frames.Next()
+		s.Printf("\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return fmt.Errorf("panic: %v", r)
+}
+
+// recoverErr is deferred to recover from a panic, reported as the
+// error of c.
+func (s *Shell) recoverErr(c *Context) {
+	if r := recover(); r != nil {
+		if err := s.recoverPanic(c, r); err != nil {
+			c.Err(err)
+		}
+	}
+}
+
+// runFunc runs the RunE or Func of cmd, recovering from its panics
+// before the After functions run.
+func (s *Shell) runFunc(c *Context, cmd *Cmd) {
+	defer s.recoverErr(c)
+	if cmd.RunE != nil {
+		if err := cmd.RunE(c); err != nil {
+			c.Err(err)
+		}
+		return
+	}
+	cmd.Func(c)
+}
+
 func (s *Shell) readLine() (line string, err error) {
 	consumer := make(chan lineString)
 	defer close(consumer)
@@ -293,6 +713,7 @@ func (s *Shell) readLine() (line string, err error) {
 
 func (s *Shell) read() ([]string, error) {
 	s.rawArgs = nil
+	s.rawLine = ""
 	heredoc := false
 	eof := ""
 	// heredoc multiline
@@ -312,10 +733,11 @@ func (s *Shell) read() ([]string, error) {
 	})
 
 	s.rawArgs = strings.Fields(lines)
+	s.rawLine = lines
 
 	if heredoc {
 		s := strings.SplitN(lines, "<<", 2)
-		args, err1 := shlex.Split(s[0])
+		args, err1 := splitArgs(s[0])
 
 		arg := strings.TrimSuffix(strings.SplitN(s[1], "\n", 2)[1], eof)
 		args = append(args, arg)
@@ -327,7 +749,7 @@ func (s *Shell) read() ([]string, error) {
 
 	lines = strings.Replace(lines, "\\\n", " \n", -1)
 
-	args, err1 := shlex.Split(lines)
+	args, err1 := splitArgs(lines)
 	if err1 != nil {
 		return args, err1
 	}
@@ -335,6 +757,22 @@ func (s *Shell) read() ([]string, error) {
 	return args, err
 }
 
+// splitArgs tokenizes line like a shell does.
+func splitArgs(line string) ([]string, error) {
+	args, err := shlex.Split(line)
+	if err != nil {
+		reason := err.Error()
+		switch {
+		case strings.Contains(reason, "closing quote"):
+			reason = "unterminated quote"
+		case strings.Contains(reason, "escape character"):
+			reason = "unterminated escape"
+		}
+		return args, errors.New("parse error: " + reason)
+	}
+	return args, nil
+}
+
 func (s *Shell) readMultiLinesFunc(f func(string) bool) (string, error) {
 	var lines bytes.Buffer
 	currentLine := 0
@@ -369,6 +807,24 @@ func (s *Shell) initCompleters() {
 	})
 }
 
+// Suggest returns the completion suggestions for the word starting
+// with prefix after the words of args, as offered by the shell's
+// completer.
+func (s *Shell) Suggest(args []string, prefix string) []Suggestion {
+	ic := iCompleter{shell: s, cmd: s.rootCmd}
+	return ic.getWords(prefix, args)
+}
+
+// Complete returns the completion suggestions for line, as if tab was
+// pressed at its end. If line ends with a space the next word is
+// completed, otherwise its last word.
+func (s *Shell) Complete(line string) []Suggestion {
+	ic := iCompleter{shell: s, cmd: s.rootCmd}
+	r := []rune(line)
+	_, suggestions := ic.suggest(r, len(r))
+	return suggestions
+}
+
 func (s *Shell) setCompleter(completer readline.AutoCompleter) {
 	config := s.reader.scanner.Config.Clone()
 	config.AutoComplete = completer
@@ -383,22 +839,38 @@ func (s *Shell) CustomCompleter(completer readline.AutoCompleter) {
 
 // AddCmd adds a new command handler.
 // This only adds top level commands.
+// Commands can be added and deleted while the shell is running.
 func (s *Shell) AddCmd(cmd *Cmd) {
 	s.rootCmd.AddCmd(cmd)
 }
 
-// DeleteCmd deletes a top level command.
+// DeleteCmd deletes a top level command, or a nested one if name is a
+// path. See Cmd.DeleteCmd.
 func (s *Shell) DeleteCmd(name string) {
 	s.rootCmd.DeleteCmd(name)
 }
 
 // NotFound adds a generic function for all inputs.
 // It is called if the shell input could not be handled by any of the
-// added commands.
+// added commands, with the input in Context.RawArgs. The handler owns
+// the input, it calls Context.Unhandled to get the default unknown
+// command message.
 func (s *Shell) NotFound(f func(*Context)) {
 	s.generic = f
 }
 
+// Before adds a function to run before every command.
+// It runs before the Before functions of the commands.
+func (s *Shell) Before(f func(*Context)) {
+	s.before = f
+}
+
+// After adds a function to run after every command, even if the command
+// panics. It runs after the After functions of the commands.
+func (s *Shell) After(f func(*Context)) {
+	s.after = f
+}
+
 // AutoHelp sets if ishell should trigger help message if
 // a command's arg is "help". Defaults to true.
 //
@@ -408,6 +880,77 @@ func (s *Shell) AutoHelp(enable bool) {
 	s.autoHelp = enable
 }
 
+// SetExitCommand renames the built-in exit command. Defaults to "exit".
+func (s *Shell) SetExitCommand(name string) {
+	if name == "" {
+		panic("exit command name must not be empty")
+	}
+	if s.exitCmd != nil {
+		s.rootCmd.DeleteCmd(s.exitCmd.Name)
+		s.addExitCmd(name)
+	}
+}
+
+// SetExitConfirm sets a function called when the exit command runs, or
+// on Ctrl-D without an EOF handler. The shell only stops if it returns
+// true.
+func (s *Shell) SetExitConfirm(confirm func() bool) {
+	s.exitConfirm = confirm
+}
+
+// SetHelpCommand renames the built-in help command, which is also the
+// arg triggering AutoHelp. Defaults to "help".
+func (s *Shell) SetHelpCommand(name string) {
+	if name == "" {
+		panic("help command name must not be empty")
+	}
+	unlock := s.rootCmd.lockTree()
+	s.rootCmd.helpCmdName = name
+	invalidateHelp()
+	unlock()
+	if s.helpCmd != nil {
+		s.rootCmd.DeleteCmd(s.helpCmd.Name)
+		s.addHelpCmd(name)
+	}
+}
+
+// DisableAutoHelp removes the built-in help command, e.g. to add
+// another one in its place.
+func (s *Shell) DisableAutoHelp() {
+	if s.helpCmd != nil {
+		s.rootCmd.DeleteCmd(s.helpCmd.Name)
+		s.helpCmd = nil
+	}
+}
+
+// SetErrorHandler sets the function called with the errors of the
+// commands in place of printing them.
+func (s *Shell) SetErrorHandler(f func(err error)) {
+	s.errorHandler = f
+}
+
+// SetPanicHandler sets the function called when a command, its Before
+// and After functions or Authorize panic, in place of printing the stack
+// and reporting the panic as an error. For a panic of the command, the
+// After functions run once it returns. A panic of Authorize still denies
+// the command.
+func (s *Shell) SetPanicHandler(f func(c *Context, r interface{})) {
+	s.panicHandler = f
+}
+
+// SetHaltOnError sets if RunScript stops at the first failing command.
+// If disabled, errors are printed and the script continues. Defaults
+// to true.
+func (s *Shell) SetHaltOnError(halt bool) {
+	s.haltOnError = halt
+}
+
+// SetHelpOnEmptyFunc sets if ishell should display the help of a command
+// without Func that has subcommands when it is invoked. Defaults to true.
+func (s *Shell) SetHelpOnEmptyFunc(enable bool) {
+	s.helpOnEmptyFunc = enable
+}
+
 // Interrupt adds a function to handle keyboard interrupt (Ctrl-c).
 // count is the number of consecutive times that Ctrl-c has been pressed.
 // i.e. any input apart from Ctrl-c resets count to 0.
@@ -421,6 +964,33 @@ func (s *Shell) EOF(f func(c *Context)) {
 	s.eof = f
 }
 
+// SetPromptFunc sets a function returning the prompt, called before
+// each line is read. It overrides the prompt set with SetPrompt, a nil
+// f restores it.
+func (s *Shell) SetPromptFunc(f func() string) {
+	s.reader.promptFunc = f
+}
+
+// SetMultiPromptFunc is SetPromptFunc for the prompt of the lines
+// following the first one of a multiline input, see SetMultiPrompt.
+func (s *Shell) SetMultiPromptFunc(f func() string) {
+	s.reader.multiPromptFunc = f
+}
+
+// SetEmptyLineHandler sets a function called when an empty line is
+// entered, e.g. to repeat the last command. By default the prompt is
+// shown again.
+func (s *Shell) SetEmptyLineHandler(f func()) {
+	s.emptyLineHandler = f
+}
+
+// SetEOFHandler sets a function called on EOF (Ctrl-D), the shell stops
+// if it returns true. It takes precedence over the function set with
+// EOF. By default the shell stops, see SetExitConfirm.
+func (s *Shell) SetEOFHandler(f func() bool) {
+	s.eofHandler = f
+}
+
 // SetHistoryPath sets where readlines history file location. Use an empty
 // string to disable history file. It is empty by default.
 func (s *Shell) SetHistoryPath(path string) {
@@ -432,6 +1002,58 @@ func (s *Shell) SetHistoryPath(path string) {
 	s.reader.scanner, _ = readline.NewEx(config)
 }
 
+// SetHistoryFile sets the file the history is loaded from and each
+// accepted line appended to, see SetHistoryPath. A missing file is
+// created, one that cannot be opened leaves the history in memory only.
+func (s *Shell) SetHistoryFile(path string) {
+	s.SetHistoryPath(path)
+}
+
+// SetHistoryLimit sets the maximum number of lines kept in the history,
+// the oldest ones are dropped from the history file when it is loaded.
+// Defaults to 500, a negative n disables the history.
+func (s *Shell) SetHistoryLimit(n int) {
+	// the cloned config gets a new history, loaded with the limit, which
+	// replaces and closes the current one
+	config := s.reader.scanner.Config.Clone()
+	config.HistoryLimit = n
+	s.reader.scanner.SetConfig(config)
+}
+
+// SetVimMode sets if the line is edited with vi key bindings, starting
+// in insert mode. Defaults to false.
+func (s *Shell) SetVimMode(enable bool) {
+	s.reader.scanner.SetVimMode(enable)
+}
+
+// BindKey makes key act as the readline key action, e.g.
+// BindKey(readline.CharNext, readline.CharTab) completes with Ctrl-N.
+// The action is one of the readline Char constants: CharTab completes,
+// CharPrev and CharNext browse the history, CharBckSearch and
+// CharFwdSearch search it, CharLineStart, CharLineEnd, CharBackward and
+// CharForward move the cursor, CharKill, CharCtrlU and CharCtrlW
+// delete and CharCtrlL clears the screen. An action of 0 ignores key.
+// Only single rune keys, control characters or printable runes, can be
+// bound. Escape sequences such as the arrow keys cannot.
+func (s *Shell) BindKey(key, action rune) {
+	if s.keyBindings == nil {
+		s.keyBindings = make(map[rune]rune)
+		config := s.reader.scanner.Config.Clone()
+		config.FuncFilterInputRune = s.filterKey
+		s.reader.scanner.SetConfig(config)
+	}
+	s.keyBindings[key] = action
+}
+
+// filterKey maps the keys bound with BindKey to their action.
+func (s *Shell) filterKey(r rune) (rune, bool) {
+	action, ok := s.keyBindings[r]
+	if !ok {
+		return r, true
+	}
+	return action, action != 0
+}
+
 // SetHomeHistoryPath is a convenience method that sets the history path
 // in user's home directory.
 func (s *Shell) SetHomeHistoryPath(path string) {
@@ -454,12 +1076,66 @@ func (s *Shell) SetHomeHistoryPath(path string) {
 	s.SetHistoryPath(abspath)
 }
 
-// SetOut sets the writer to write outputs to.
+// SetOut sets the writer to write outputs to. It is SetOutputWriter.
 func (s *Shell) SetOut(writer io.Writer) {
+	s.SetOutputWriter(writer)
+}
+
+// SetOutputWriter sets the writer the command outputs, the help and
+// the completion tips are written to. The writers added with TeeOutput
+// are kept.
+func (s *Shell) SetOutputWriter(writer io.Writer) {
+	if t, ok := s.writer.(*teeWriter); ok {
+		s.writer = newTeeWriter(writer, t.tees)
+		return
+	}
 	s.writer = writer
 }
 
-// SetPager sets the pager and its arguments for paged output
+// TeeOutput duplicates the shell output to writer, e.g. a file for a
+// session transcript. A nil writer removes the writers added so far.
+func (s *Shell) TeeOutput(writer io.Writer) {
+	out := s.writer
+	var tees []io.Writer
+	if t, ok := s.writer.(*teeWriter); ok {
+		out, tees = t.out, t.tees
+	}
+	if writer == nil {
+		s.writer = out
+		return
+	}
+	s.writer = newTeeWriter(out, append(tees, writer))
+}
+
+// teeWriter writes to out and tees.
+type teeWriter struct {
+	io.Writer
+	out  io.Writer
+	tees []io.Writer
+}
+
+func newTeeWriter(out io.Writer, tees []io.Writer) *teeWriter {
+	return &teeWriter{
+		Writer: io.MultiWriter(append([]io.Writer{out}, tees...)...),
+		out:    out,
+		tees:   tees,
+	}
+}
+
+// Flush flushes the writers that are buffered.
+func (w *teeWriter) Flush() error {
+	var errs []error
+	for _, writer := range append([]io.Writer{w.out}, w.tees...) {
+		if f, ok := writer.(interface{ Flush() error }); ok {
+			errs = append(errs, f.Flush())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetPager sets the pager and its arguments for paged output.
+// Defaults to the PAGER environment variable, or "less" ("more" on
+// windows) if it is not set.
 func (s *Shell) SetPager(pager string, args []string) {
 	s.pager = pager
 	s.pagerArgs = args
@@ -661,6 +1337,168 @@ func (s *Shell) IgnoreCase(ignore bool) {
 	s.ignoreCase = ignore
 }
 
+// SetHideDisabled sets if the commands disabled by their Enabled
+// function are excluded from help and completion, rather than marked
+// disabled. Defaults to false.
+func (s *Shell) SetHideDisabled(hide bool) {
+	s.help.hideDisabled = hide
+}
+
+// SetHideUnauthorized specifies whether commands rejected by their
+// Authorize function are excluded from completion. Defaults to false.
+func (s *Shell) SetHideUnauthorized(hide bool) {
+	s.hideUnauthorized = hide
+}
+
+// SetVerboseHelp specifies whether help texts list deprecated commands.
+// Defaults to false.
+func (s *Shell) SetVerboseHelp(verbose bool) {
+	s.help.verbose = verbose
+}
+
+// SetCategoryOrder sets the order of the command categories in help texts.
+// Categories not in order are listed after, alphabetically.
+func (s *Shell) SetCategoryOrder(order []string) {
+	s.help.categoryOrder = order
+}
+
+// SetCommandSort sets the order of commands in help texts and
+// Children. Defaults to SortAlphabetical.
+func (s *Shell) SetCommandSort(mode SortMode) {
+	defer s.rootCmd.lockTree()()
+	s.rootCmd.sortMode = mode
+	invalidateHelp()
+}
+
+// SetCaseInsensitive specifies whether command names and aliases are
+// matched regardless of case. Unlike IgnoreCase, the input is not modified
+// and commands can be registered in any case.
+// Defaults to false i.e. commands are matched exactly.
+func (s *Shell) SetCaseInsensitive(enable bool) {
+	s.match.ignoreCase = enable
+}
+
+// SetAllowPrefixMatch specifies whether a command can be invoked with
+// an unambiguous prefix of its name e.g. 'stat' for 'status'.
+// Defaults to false.
+func (s *Shell) SetAllowPrefixMatch(allow bool) {
+	s.match.prefixMatch = allow
+}
+
+// SetArgSeparator sets the token after which all the arguments of a
+// command are positional, even if they look like pair arguments e.g.
+// 'run -- ls -la'. An empty sep disables it. Defaults to "--".
+func (s *Shell) SetArgSeparator(sep string) {
+	s.argSeparator = sep
+}
+
+// SetStrictParams specifies whether param commands only match the
+// values parsing as their ParamType, letting a later param sibling or
+// the NotFound handler take the others. Defaults to false.
+func (s *Shell) SetStrictParams(strict bool) {
+	s.match.strictParams = strict
+}
+
+// SetAliasNormalizer sets a function applied to both the input and the
+// names and aliases of commands before comparing them, when no exact
+// match is found, e.g. to accept 'RM' and 'r-m' for 'rm'. Completion
+// and help still show the registered names. Defaults to nil i.e.
+// names and aliases are matched exactly.
+func (s *Shell) SetAliasNormalizer(normalize func(string) string) {
+	s.match.normalize = normalize
+}
+
+// SetMaxCompletionItems sets the maximum number of completion tips
+// listed, the others are summarized in a last "...and N more" line.
+// The completion itself still considers all the candidates. Zero or
+// less means no limit, the default.
+func (s *Shell) SetMaxCompletionItems(n int) {
+	s.maxCompletionItems = n
+}
+
+// SetCompletionSink sets the writer the completion candidates are
+// written to, one per line with their help after a tab, for external
+// pickers. The completion tips are then not printed. A nil w restores
+// the default.
+func (s *Shell) SetCompletionSink(w io.Writer) {
+	s.completionSink = w
+}
+
+// SetFuzzyCompletion sets if completion matches the words containing
+// the typed runes in order, e.g. "gc" completes to "git-commit". Words
+// starting with the typed text are listed first. Defaults to false.
+func (s *Shell) SetFuzzyCompletion(fuzzy bool) {
+	s.fuzzyCompletion = fuzzy
+}
+
+// SetSlashPaths sets if a first word containing slashes is split into
+// the words of a command path, so that "users/42/delete" runs the same
+// command as "users 42 delete". Completion then offers the subcommands
+// of a partial path joined with slashes. Defaults to false.
+func (s *Shell) SetSlashPaths(enable bool) {
+	s.slashPaths = enable
+}
+
+// splitSlashPath splits the first word of args on slashes if enabled
+// with SetSlashPaths.
+func (s *Shell) splitSlashPath(args []string) []string {
+	if !s.slashPaths || len(args) == 0 {
+		return args
+	}
+	path := splitSlashes(args[0])
+	if len(path) == 0 {
+		return args
+	}
+	return append(path, args[1:]...)
+}
+
+// splitSlashes returns the non empty slash separated parts of word.
+func splitSlashes(word string) []string {
+	var parts []string
+	for _, part := range strings.Split(word, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// SetSuggestDistance sets the maximum edit distance between an unknown
+// command and a registered command name or alias for the latter to be
+// suggested. Use 0 to disable suggestions. Defaults to 2.
+func (s *Shell) SetSuggestDistance(distance int) {
+	s.suggestDistance = distance
+}
+
+// ClearScreen clears the screen. It does nothing if the shell does not
+// write to a terminal.
+func (s *Shell) ClearScreen() error {
+	if !s.isTerminal() {
+		return nil
+	}
+	return clearScreen(s)
+}
+
+// isTerminal reports if the shell writes to a terminal.
+func (s *Shell) isTerminal() bool {
+	f, ok := s.outFile()
+	return ok && isTerminalFd(int(f.Fd()))
+}
+
+// isTerminalFd reports if the file descriptor fd is a terminal.
+var isTerminalFd = readline.IsTerminal
+
+// outFile returns the file the shell writes to, if any, ignoring the
+// writers added with TeeOutput.
+func (s *Shell) outFile() (*os.File, bool) {
+	writer := s.writer
+	if t, ok := writer.(*teeWriter); ok {
+		writer = t.out
+	}
+	f, ok := writer.(*os.File)
+	return f, ok
+}
+
 // ProgressBar returns the progress bar for the shell.
 func (s *Shell) ProgressBar() ProgressBar {
 	return s.progressBar
@@ -670,12 +1508,19 @@ func newContext(s *Shell, cmd *Cmd, args []string) *Context {
 	if cmd == nil {
 		cmd = &Cmd{}
 	}
+	// the copy reads the subcommands, which AddCmd may be changing
+	unlock := cmd.rlockTree()
+	defer unlock()
 	return &Context{
 		Actions:     s.Actions,
 		progressBar: copyShellProgressBar(s),
 		Args:        args,
 		RawArgs:     s.rawArgs,
+		Line:        s.rawLine,
+		writer:      s.writer,
+		shell:       s,
 		Cmd:         *cmd,
+		Ctx:         context.Background(),
 		contextValues: func() contextValues {
 			values := contextValues{}
 			for k := range s.contextValues {
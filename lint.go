@@ -0,0 +1,57 @@
+package ishell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lintStatics is the number of static siblings from which Lint reports
+// a param capturing any word.
+const lintStatics = 3
+
+// Lint walks the command tree and reports the likely routing mistakes:
+//
+//   - a param capturing any word next to many static commands, whose
+//     typos it then captures
+//   - a param or catch-all capturing any word before other params,
+//     which are never matched
+//   - a command with neither a function nor subcommands
+//
+// Params with a ParamType capture any word too, unless SetStrictParams
+// is enabled.
+// It is meant as a sanity check during development, e.g. in a test.
+func (s *Shell) Lint() []string {
+	issues := lintChildren(nil, s.rootCmd, s.match.strictParams)
+	s.rootCmd.Walk(func(path []string, cmd *Cmd) error {
+		if !cmd.runnable() && len(cmd.Children()) == 0 {
+			issues = append(issues, fmt.Sprintf("%s: no function and no subcommands", strings.Join(path, "/")))
+		}
+		issues = append(issues, lintChildren(path, cmd, s.match.strictParams)...)
+		return nil
+	})
+	return issues
+}
+
+// lintChildren reports the params of c, at path, shadowing its other
+// subcommands. Typed params only capture their values with strict
+// params.
+func lintChildren(path []string, c *Cmd, strictParams bool) (issues []string) {
+	defer c.rlockTree()()
+
+	fullName := func(cmd *Cmd) string {
+		return strings.Join(append(path[:len(path):len(path)], cmd.displayName()), "/")
+	}
+	for i, param := range c.paramChildren {
+		if param.pattern != nil || strictParams && param.ParamType != ParamString {
+			continue
+		}
+		for _, next := range c.paramChildren[i+1:] {
+			issues = append(issues, fmt.Sprintf("%s: never matched, %s captures any word first", fullName(next), fullName(param)))
+		}
+		if n := len(c.staticChildren); n >= lintStatics {
+			issues = append(issues, fmt.Sprintf("%s: captures any word, including the typos of its %d static siblings", fullName(param), n))
+		}
+		break
+	}
+	return issues
+}
@@ -0,0 +1,59 @@
+package ishell
+
+// menuState is the state of menu completion between two presses of tab.
+type menuState struct {
+	// line is the buffer after the last candidate was inserted.
+	line string
+	// head is the text before the word being completed.
+	head  string
+	items []string
+	index int
+}
+
+// SetMenuComplete specifies whether pressing tab repeatedly cycles
+// through the candidates, inserting each one in turn, instead of only
+// listing them. The readline library has no menu completion of its own,
+// so the shell rewrites the line itself. Defaults to false.
+func (s *Shell) SetMenuComplete(enable bool) {
+	s.menuComplete = enable
+	s.menu = menuState{}
+}
+
+// menuStart inserts the first of several candidates for the word
+// from start to pos and reports if it did, when menu completion is enabled.
+func (ic iCompleter) menuStart(line []rune, pos, start int, candidates []string) bool {
+	if ic.shell == nil || !ic.shell.menuComplete || len(candidates) < 2 || pos != len(line) {
+		return false
+	}
+	ic.shell.menu = menuState{
+		head:  string(line[:start]),
+		items: candidates,
+	}
+	ic.menuInsert()
+	return true
+}
+
+// menuNext inserts the next candidate and reports if it did, when line
+// is unchanged since the previous insertion. Any other line ends the
+// cycle.
+func (ic iCompleter) menuNext(line []rune) bool {
+	if ic.shell == nil || !ic.shell.menuComplete {
+		return false
+	}
+	menu := &ic.shell.menu
+	if len(menu.items) == 0 || menu.line != string(line) {
+		*menu = menuState{}
+		return false
+	}
+	menu.index = (menu.index + 1) % len(menu.items)
+	ic.menuInsert()
+	return true
+}
+
+// menuInsert replaces the word being completed with the current
+// candidate.
+func (ic iCompleter) menuInsert() {
+	menu := &ic.shell.menu
+	menu.line = menu.head + escapeWord(menu.items[menu.index], 0, true)
+	ic.shell.reader.scanner.Operation.SetBuffer(menu.line)
+}
@@ -0,0 +1,44 @@
+package ishell
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// ParamType is the type of the values of a param command.
+type ParamType uint8
+
+const (
+	// ParamString accepts any value.
+	ParamString ParamType = iota
+	// ParamInt accepts base 10 integers.
+	ParamInt
+	// ParamUUID accepts UUIDs in their canonical form, e.g.
+	// "123e4567-e89b-12d3-a456-426614174000".
+	ParamUUID
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// String returns the name of the type.
+func (t ParamType) String() string {
+	switch t {
+	case ParamInt:
+		return "int"
+	case ParamUUID:
+		return "uuid"
+	}
+	return "string"
+}
+
+// valid reports if value parses as t.
+func (t ParamType) valid(value string) bool {
+	switch t {
+	case ParamInt:
+		_, err := strconv.Atoi(value)
+		return err == nil
+	case ParamUUID:
+		return uuidPattern.MatchString(value)
+	}
+	return true
+}
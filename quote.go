@@ -0,0 +1,70 @@
+package ishell
+
+import "strings"
+
+// scanWord returns the index in line of the start of its last word as
+// tokenized by shlex, and the quote left open in it if any. The start
+// is len(line) if line ends with a separator.
+func scanWord(line []rune) (start int, quote rune) {
+	start = 0
+	inWord := false
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case quote == '"':
+			if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				quote = 0
+			}
+		case strings.ContainsRune(" \t\r\n", r):
+			inWord = false
+			continue
+		default:
+			if r == '\\' {
+				escaped = true
+			} else if r == '\'' || r == '"' {
+				quote = r
+			}
+		}
+		if !inWord {
+			start, inWord = i, true
+		}
+	}
+	if !inWord {
+		start = len(line)
+	}
+	return start, quote
+}
+
+// escapeWord escapes the runes of s that shlex would not read
+// literally, inside quote if it is not 0. first tells if s starts the
+// word, where a '#' starts a comment.
+func escapeWord(s string, quote rune, first bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch quote {
+		case '\'':
+			if r == '\'' {
+				// close the quote around an escaped quote
+				b.WriteString(`'\'`)
+			}
+		case '"':
+			if r == '"' || r == '\\' {
+				b.WriteRune('\\')
+			}
+		default:
+			if strings.ContainsRune(" \t\r\n'\"\\", r) || r == '#' && i == 0 && first {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
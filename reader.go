@@ -22,9 +22,13 @@ type (
 		buf          *bytes.Buffer
 		prompt       string
 		multiPrompt  string
-		showPrompt   bool
-		completer    readline.AutoCompleter
-		defaultInput string
+		// promptFunc and multiPromptFunc override prompt and
+		// multiPrompt if not nil.
+		promptFunc      func() string
+		multiPromptFunc func() string
+		showPrompt      bool
+		completer       readline.AutoCompleter
+		defaultInput    string
 		sync.Mutex
 	}
 )
@@ -34,8 +38,14 @@ type (
 func (s *shellReader) rlPrompt() string {
 	if s.showPrompt {
 		if s.readingMulti {
+			if s.multiPromptFunc != nil {
+				return s.multiPromptFunc()
+			}
 			return s.multiPrompt
 		}
+		if s.promptFunc != nil {
+			return s.promptFunc()
+		}
 		return s.prompt
 	}
 	return ""
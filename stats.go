@@ -0,0 +1,69 @@
+package ishell
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CmdStat is the usage statistics of a command.
+type CmdStat struct {
+	// Count is the number of times the command ran.
+	Count int
+	// Total is the accumulated execution time of the command.
+	Total time.Duration
+}
+
+type cmdStats struct {
+	enabled bool
+	stats   map[string]CmdStat
+	sync.Mutex
+}
+
+func (s *cmdStats) record(name string, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]CmdStat)
+	}
+	stat := s.stats[name]
+	stat.Count++
+	stat.Total += d
+	s.stats[name] = stat
+}
+
+// fullName returns the registered path of c e.g. "users/:id".
+func (c *Cmd) fullName() string {
+	var names []string
+	for cmd := c; cmd != nil && cmd.Name != ""; cmd = cmd.parent {
+		names = append([]string{cmd.displayName()}, names...)
+	}
+	return strings.Join(names, spliter)
+}
+
+// EnableStats specifies whether the shell records the invocation count and
+// execution time of commands. Defaults to false.
+func (s *Shell) EnableStats(enable bool) {
+	s.stats.Lock()
+	defer s.stats.Unlock()
+	s.stats.enabled = enable
+}
+
+// Stats returns the statistics of the commands that ran since stats are
+// enabled, keyed by registered command path e.g. "users/:id".
+func (s *Shell) Stats() map[string]CmdStat {
+	s.stats.Lock()
+	defer s.stats.Unlock()
+	stats := make(map[string]CmdStat, len(s.stats.stats))
+	for k, v := range s.stats.stats {
+		stats[k] = v
+	}
+	return stats
+}
+
+// ResetStats clears the recorded statistics.
+func (s *Shell) ResetStats() {
+	s.stats.Lock()
+	defer s.stats.Unlock()
+	s.stats.stats = nil
+}
@@ -0,0 +1,106 @@
+package ishell
+
+import (
+	"reflect"
+	"strings"
+)
+
+// structTag is the key of the struct tags read by AddStruct.
+const structTag = "ishell"
+
+// AddStruct adds a top level command for each exported method of v with
+// the signature func(*Context). The command of method Bar is named
+// "bar".
+//
+// A field tagged `ishell:"method=Bar,name=foo,help=...,alias=f"`
+// describes the command of method Bar. The alias key can be repeated
+// and values cannot contain commas. Fields are usually blank, e.g.
+//
+//	_ struct{} `ishell:"method=Greet,help=greet the user,alias=hi"`
+//
+// AddStruct panics if v is not a struct or a pointer to one, or if a
+// tag is malformed or describes a missing or unsuitable method.
+// Methods with other signatures and no tag are skipped.
+func (s *Shell) AddStruct(v interface{}) {
+	for _, cmd := range structCmds(v) {
+		s.AddCmd(cmd)
+	}
+}
+
+// structCmds returns the commands of the methods of v, in method order.
+func structCmds(v interface{}) []*Cmd {
+	value := reflect.ValueOf(v)
+	typ := value.Type()
+	if typ.Kind() != reflect.Struct && (typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct) {
+		panic("AddStruct needs a struct or a pointer to a struct, got " + typ.String())
+	}
+
+	tags := structTags(reflect.Indirect(value).Type())
+	for name := range tags {
+		if _, ok := typ.MethodByName(name); !ok {
+			panic("method '" + name + "' of " + typ.String() + " not found")
+		}
+	}
+
+	var cmds []*Cmd
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		f, ok := value.Method(i).Interface().(func(*Context))
+		cmd, tagged := tags[method.Name]
+		if !ok {
+			if tagged {
+				panic("method '" + method.Name + "' of " + typ.String() + " is not a func(*Context)")
+			}
+			continue
+		}
+		if !tagged {
+			cmd = &Cmd{}
+		}
+		if cmd.Name == "" {
+			cmd.Name = strings.ToLower(method.Name)
+		}
+		cmd.Func = f
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// structTags parses the AddStruct tags of the fields of typ, keyed by
+// method name.
+func structTags(typ reflect.Type) map[string]*Cmd {
+	tags := make(map[string]*Cmd)
+	for i := 0; i < typ.NumField(); i++ {
+		tag, ok := typ.Field(i).Tag.Lookup(structTag)
+		if !ok {
+			continue
+		}
+		cmd := &Cmd{}
+		method := ""
+		for _, kv := range strings.Split(tag, ",") {
+			kv := strings.SplitN(kv, "=", 2)
+			if len(kv) != 2 {
+				panic("malformed tag '" + tag + "' in " + typ.String())
+			}
+			switch kv[0] {
+			case "method":
+				method = kv[1]
+			case "name":
+				cmd.Name = kv[1]
+			case "help":
+				cmd.Help = kv[1]
+			case "alias":
+				cmd.Aliases = append(cmd.Aliases, kv[1])
+			default:
+				panic("unknown key '" + kv[0] + "' in tag '" + tag + "' in " + typ.String())
+			}
+		}
+		if method == "" {
+			panic("tag '" + tag + "' in " + typ.String() + " has no method")
+		}
+		if _, ok := tags[method]; ok {
+			panic("method '" + method + "' of " + typ.String() + " is tagged twice")
+		}
+		tags[method] = cmd
+	}
+	return tags
+}
@@ -0,0 +1,84 @@
+package ishell
+
+import (
+	"io"
+
+	"github.com/liqianrain/readline"
+)
+
+// StartSubShell reads and runs commands from the subcommands of root
+// with prompt, until the exit command or EOF is entered. It then
+// restores the commands and the prompt of the shell and returns. The
+// output and the history of the shell are shared with the sub-shell.
+// The built-in help and clear commands of the shell are run unless root
+// has commands of the same names.
+func (c *Context) StartSubShell(root *Cmd, prompt string) {
+	s := c.shell
+	rootCmd, oldPrompt, builtins := s.rootCmd, s.reader.prompt, s.builtins
+	if builtins == nil {
+		s.builtins = registeredCmds(rootCmd, s.helpCmd, s.clearCmd)
+	}
+	s.rootCmd = root
+	c.SetPrompt(prompt)
+	if !s.customCompleter {
+		s.initCompleters()
+	}
+	defer func() {
+		s.rootCmd, s.builtins = rootCmd, builtins
+		c.SetPrompt(oldPrompt)
+		if !s.customCompleter {
+			s.initCompleters()
+		}
+	}()
+
+	exit := defaultExitName
+	if s.exitCmd != nil {
+		exit = s.exitCmd.Name
+	}
+	for {
+		line, err := s.read()
+		switch {
+		case err == io.EOF:
+			return
+		case err == readline.ErrInterrupt:
+			continue
+		case err != nil:
+			s.printError(err)
+			continue
+		case len(line) == 0:
+			continue
+		case len(line) == 1 && line[0] == exit:
+			return
+		}
+		if err := handleInput(s, line); err != nil {
+			s.printError(err)
+		}
+	}
+}
+
+// registeredCmds returns the commands of cmds still registered as
+// subcommands of root.
+func registeredCmds(root *Cmd, cmds ...*Cmd) []*Cmd {
+	defer root.rlockTree()()
+	var registered []*Cmd
+	for _, cmd := range cmds {
+		if cmd != nil && root.staticChildren[cmd.Name] == cmd {
+			registered = append(registered, cmd)
+		}
+	}
+	return registered
+}
+
+// builtinCmd returns the built-in command of the shell named by the
+// first word of line while a sub-shell runs, or nil.
+func (s *Shell) builtinCmd(line []string) *Cmd {
+	if len(line) == 0 {
+		return nil
+	}
+	for _, cmd := range s.builtins {
+		if s.match.equal(line[0], cmd.Name) {
+			return cmd
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package ishell
+
+import (
+	"strings"
+)
+
+const defaultSuggestDistance = 2
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(t)]
+}
+
+// closestChild returns the name or alias of the static command of cmds
+// closest to name, if within distance edits. It returns an empty string
+// otherwise. Hidden and disabled commands are not suggested.
+func closestChild(cmds []*Cmd, name string, distance int, m matcher) string {
+	if distance <= 0 {
+		return ""
+	}
+	if m.ignoreCase {
+		name = strings.ToLower(name)
+	}
+	best, bestDistance := "", distance+1
+	try := func(word string) {
+		w := word
+		if m.ignoreCase {
+			w = strings.ToLower(w)
+		}
+		if d := levenshtein(name, w); d < bestDistance || (d == bestDistance && word < best) {
+			best, bestDistance = word, d
+		}
+	}
+	for _, cmd := range cmds {
+		if cmd.isParam() || cmd.Hidden || cmd.disabled() {
+			continue
+		}
+		try(cmd.Name)
+		for _, alias := range cmd.Aliases {
+			try(alias)
+		}
+	}
+	return best
+}
+
+// fuzzyMatch reports if the runes of prefix appear in order in word.
+// The rank of the match is 0 if word starts with prefix, and lower the
+// more runes of word are skipped.
+func fuzzyMatch(word, prefix string) (int, bool) {
+	if strings.HasPrefix(word, prefix) {
+		return 0, true
+	}
+	p := []rune(prefix)
+	j, skipped := 0, 0
+	for _, r := range word {
+		if j == len(p) {
+			break
+		}
+		if r == p[j] {
+			j++
+		} else {
+			skipped++
+		}
+	}
+	if j < len(p) {
+		return 0, false
+	}
+	return -skipped, true
+}
@@ -0,0 +1,63 @@
+package ishell
+
+import (
+	"os"
+)
+
+// Theme holds the color functions used to render help texts and completion
+// tips. A nil function leaves the text uncolored. Functions returned by
+// github.com/fatih/color SprintFunc can be used directly.
+//
+// Colors are disabled if the NO_COLOR environment variable is set to a
+// non empty value or if the shell output is not a terminal.
+type Theme struct {
+	// Command colors the names of commands and arguments.
+	Command func(a ...interface{}) string
+	// Optional colors the square brackets of optional arguments.
+	Optional func(a ...interface{}) string
+	// Param colors the angle brackets of params.
+	Param func(a ...interface{}) string
+	// Help colors help messages.
+	Help func(a ...interface{}) string
+}
+
+func paint(f func(a ...interface{}) string, s string) string {
+	if f == nil || s == "" {
+		return s
+	}
+	return f(s)
+}
+
+// isZero reports if t has no colors.
+func (t Theme) isZero() bool {
+	return t.Command == nil && t.Optional == nil && t.Param == nil && t.Help == nil
+}
+
+func (t Theme) command(s string) string  { return paint(t.Command, s) }
+func (t Theme) optional(s string) string { return paint(t.Optional, s) }
+func (t Theme) param(s string) string    { return paint(t.Param, s) }
+func (t Theme) help(s string) string     { return paint(t.Help, s) }
+
+// SetTheme sets the colors of help texts and completion tips.
+func (s *Shell) SetTheme(theme Theme) {
+	s.theme = theme
+}
+
+// activeTheme returns the theme of the shell, or the uncolored theme if
+// colors are disabled.
+func (s *Shell) activeTheme() Theme {
+	if os.Getenv("NO_COLOR") != "" {
+		return Theme{}
+	}
+	if !s.isTerminal() {
+		return Theme{}
+	}
+	return s.theme
+}
+
+// helpConfig returns the options used to render help texts.
+func (s *Shell) helpConfig() helpConfig {
+	conf := s.help
+	conf.theme = s.activeTheme()
+	return conf
+}
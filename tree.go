@@ -0,0 +1,43 @@
+package ishell
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// CommandTree returns the command hierarchy of the shell, one command
+// per line indented under its parent with its help, e.g.
+//
+//	users     manage users
+//	  :id
+//	    show  show a user
+//	  list    list the users
+//
+// Commands are listed in the order of Children, params as ":name".
+// Hidden commands are left out with their subcommands. A positive
+// depth limits the number of levels listed.
+func (s *Shell) CommandTree(depth int) string {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	s.rootCmd.Walk(func(path []string, cmd *Cmd) error {
+		if cmd.Hidden {
+			return SkipChildren
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\n", strings.Repeat("  ", len(path)-1), cmd.displayName(), cmd.Help)
+		if depth > 0 && len(path) >= depth {
+			return SkipChildren
+		}
+		return nil
+	})
+	tw.Flush()
+	// drop the padding of the commands without help
+	var tree strings.Builder
+	for _, line := range strings.SplitAfter(b.String(), "\n") {
+		if line != "" {
+			tree.WriteString(strings.TrimRight(line, " \n") + "\n")
+		}
+	}
+	return tree.String()
+}
@@ -0,0 +1,33 @@
+package ishell
+
+import "errors"
+
+// SkipChildren is returned by the function passed to Walk to skip the
+// subcommands of the visited command.
+var SkipChildren = errors.New("skip children")
+
+// Walk visits the subcommands of c depth first, in the order of
+// Children. fn is called with the names leading from c to each command,
+// params being shown as ":name". If fn returns SkipChildren the
+// subcommands of the command are skipped, any other error stops the
+// walk and is returned.
+func (c *Cmd) Walk(fn func(path []string, cmd *Cmd) error) error {
+	return c.walk(nil, fn)
+}
+
+func (c *Cmd) walk(path []string, fn func(path []string, cmd *Cmd) error) error {
+	for _, child := range c.Children() {
+		childPath := append(path[:len(path):len(path)], child.displayName())
+		err := fn(childPath, child)
+		if err == SkipChildren {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := child.walk(childPath, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}